@@ -0,0 +1,108 @@
+package wrench
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Backend running docker builds of project
+type buildBackend struct {
+	// Build with docker buildx build instead of the classic docker build
+	buildx bool
+
+	// buildx builder, the current builder if empty
+	builder string
+
+	// SSH agent sockets or keys and BuildKit secrets passed to builds
+	ssh     []string
+	secrets []string
+
+	cache *buildCache
+}
+
+// Get build backend of project from Build and Cache in wrench.yml and the
+// build options. A buildx builder is created for a remote BuildKit
+// endpoint if missing.
+func getBuildBackend(ctx context.Context, c *config.Resolved, options BuildOptions) (*buildBackend, error) {
+	build := c.GetBuild()
+
+	cache, err := getBuildCache(c, options)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &buildBackend{
+		buildx:  build.Backend == "buildx" || build.Builder != "" || build.Endpoint != "" || cache.needsBuildx(),
+		builder: build.Builder,
+		ssh:     append(append([]string{}, build.Ssh...), options.Ssh...),
+		secrets: options.Secrets,
+		cache:   cache,
+	}
+
+	if build.Endpoint != "" {
+		if backend.builder == "" {
+			backend.builder = remoteBuilderName(build.Endpoint)
+		}
+		if err := createRemoteBuilder(ctx, backend.builder, build.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	return backend, nil
+}
+
+// Get name of buildx builder created for endpoint
+func remoteBuilderName(endpoint string) string {
+	return fmt.Sprintf("wrench-%x", sha1.Sum([]byte(endpoint)))[:15]
+}
+
+// Create buildx builder with the remote driver for BuildKit endpoint,
+// nothing is done if builder exists
+func createRemoteBuilder(ctx context.Context, name string, endpoint string) error {
+	if exitcode, _ := utils.RunCmdContext(ctx, fmt.Sprintf("docker buildx inspect %s", utils.ShellQuote(name))); exitcode == 0 {
+		return nil
+	}
+
+	log.Infof("Creating buildx builder %s for %s", name, endpoint)
+
+	cmd_string := fmt.Sprintf("docker buildx create --name %s --driver remote %s", utils.ShellQuote(name), utils.ShellQuote(endpoint))
+	if exitcode, out := utils.RunCmdContext(ctx, cmd_string); exitcode != 0 {
+		return &CommandError{Command: "docker buildx create", ExitCode: exitcode, Output: out}
+	}
+	return nil
+}
+
+// Get docker build command for image with tag suffix, like -builder or
+// -test, and environment variables it needs
+func (b *buildBackend) command(suffix string) (string, []string) {
+	args := []string{"docker", "build"}
+	if b.buildx {
+		// Image is only kept in the build cache of buildx builders without
+		// --load
+		args = []string{"docker", "buildx", "build", "--load"}
+		if b.builder != "" {
+			args = append(args, "--builder", b.builder)
+		}
+	}
+
+	base := len(args)
+
+	for _, ssh := range b.ssh {
+		args = append(args, "--ssh", ssh)
+	}
+	for _, secret := range b.secrets {
+		args = append(args, "--secret", secret)
+	}
+	args = append(args, b.cache.args(suffix, b.buildx)...)
+
+	// SSH, secrets and cache of images are BuildKit features
+	if !b.buildx && len(args) > base {
+		return utils.ShellQuoteArgs(args), []string{"DOCKER_BUILDKIT=1"}
+	}
+	return utils.ShellQuoteArgs(args), nil
+}
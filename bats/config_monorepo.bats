@@ -0,0 +1,82 @@
+#!/usr/bin/env bats
+
+
+setup () {
+    BATS_TMP_DIR=$(mktemp -d .wrench-bats.XXXXX)
+    pushd $BATS_TMP_DIR
+
+    git init
+    git config user.name "Your Name"
+    git config user.email "you@example.com"
+
+    mkdir -p libs/base services/api services/web
+    echo "FROM alpine" > libs/base/Dockerfile
+    echo "FROM acme/base:latest" > services/api/Dockerfile
+    echo "FROM alpine" > services/web/Dockerfile
+
+    cat > wrench.yml << EOF
+Project:
+  Organization: acme
+Projects:
+  - services/api
+  - services/web
+  - libs/base
+EOF
+
+    git add .
+    git commit -m "add projects"
+    git tag -a api/v1.2.0 -m "Release api/v1.2.0"
+}
+
+teardown () {
+    popd
+    rm -rf $BATS_TMP_DIR
+}
+
+@test "MONOREPO: projects in dependency order" {
+    ret=0
+    out=$(wrench config projects) || ret=$?
+
+    echo "ret=$ret"
+    [ "$ret" -eq 0 ]
+
+    echo "out=$out"
+    [ "$(echo $out)" = "libs/base services/api services/web" ]
+}
+
+@test "MONOREPO: projects changed since ref" {
+    git checkout -b feature
+    echo "RUN true" >> libs/base/Dockerfile
+    git commit -am "change base"
+
+    ret=0
+    out=$(wrench config projects --changed-since HEAD~1) || ret=$?
+
+    echo "ret=$ret"
+    [ "$ret" -eq 0 ]
+
+    echo "out=$out"
+    [ "$(echo $out)" = "libs/base services/api" ]
+}
+
+@test "MONOREPO: project config from subdirectory" {
+    ret=0
+    out=$(cd services/api && wrench config --format '{{.Project.Organization}}/{{.Project.Name}}') || ret=$?
+
+    echo "ret=$ret"
+    [ "$ret" -eq 0 ]
+
+    echo "out=$out"
+    [ "$out" = "acme/api" ]
+}
+
+@test "MONOREPO: project version from prefixed tag" {
+    ret=0
+    out=$(wrench -C services/api config --format '{{.Project.Version}}') || ret=$?
+
+    echo "ret=$ret"
+    [ "$ret" -eq 0 ]
+
+    echo "out=$out"
+    [ "$out" = "v1.2.0" ]
+}
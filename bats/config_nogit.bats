@@ -17,7 +17,7 @@ teardown () {
     chmod +x git
 
     ret=0
-    out=$(PATH=$PWD:$PATH wrench config) || ret=$?
+    out=$(PATH=$PWD:$PATH wrench config 2>&1) || ret=$?
 
     echo "ret=$ret"
     [ "$ret" -eq 1 ]
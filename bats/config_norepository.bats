@@ -14,7 +14,7 @@ teardown () {
     git init
 
     ret=0
-    out=$(PATH=$PWD:$PATH wrench config) || ret=$?
+    out=$(PATH=$PWD:$PATH wrench config 2>&1) || ret=$?
 
     echo "ret=$ret"
     [ "$ret" -eq 1 ]
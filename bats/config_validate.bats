@@ -0,0 +1,45 @@
+#!/usr/bin/env bats
+
+setup () {
+    BATS_TMP_DIR=$(mktemp -d .wrench-bats.XXXXX)
+    pushd $BATS_TMP_DIR
+}
+
+teardown () {
+    popd
+    rm -rf $BATS_TMP_DIR
+}
+
+@test "CONFIG: validate valid config" {
+    cat > wrench.yml << EOF
+Project:
+  Name: hello
+Run:
+  syntax: echo foo
+EOF
+
+    ret=0
+    out=$(wrench config validate) || ret=$?
+
+    echo "ret=$ret"
+    echo "out=$out"
+    [ "$ret" -eq 0 ]
+    [ "$out" == "" ]
+}
+
+@test "CONFIG: validate reports line and suggestion" {
+    cat > wrench.yml << EOF
+Project:
+  Organisation: world
+Run:
+  syntax: echo foo
+EOF
+
+    ret=0
+    out=$(wrench config validate) || ret=$?
+
+    echo "ret=$ret"
+    echo "out=$out"
+    [ "$ret" -eq 1 ]
+    [ "$out" == 'wrench.yml:2:3: unknown key "Organisation" in Project, did you mean "Organization"?' ]
+}
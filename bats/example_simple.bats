@@ -38,7 +38,7 @@ teardown () {
 
 @test "EXAMPLE: build simple" {
     ret=0
-    out=$(wrench build) || ret=$?
+    out=$(wrench build 2>&1) || ret=$?
 
     echo "out=$out"
     echo "ret=$ret"
@@ -38,7 +38,7 @@ teardown () {
 
 @test "EXAMPLE: build test" {
     ret=0
-    out=$(wrench build) || ret=$?
+    out=$(wrench build 2>&1) || ret=$?
 
     echo "out=$out"
     echo "ret=$ret"
@@ -52,7 +52,7 @@ teardown () {
     wrench build
 
     ret=0
-    out=$(wrench run syntax-test) || ret=$?
+    out=$(wrench run syntax-test 2>&1) || ret=$?
 
     echo "out=$out"
     echo "ret=$ret"
@@ -1,184 +1,575 @@
-package main
+package wrench
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/spf13/cobra"
 	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/dockerfile"
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/report"
+	"github.com/tomologic/wrench/secrets"
 	"github.com/tomologic/wrench/utils"
 )
 
-var flag_rebuild bool
-
-func AddBuildToWrench(rootCmd *cobra.Command) {
-	var cmdBuild = &cobra.Command{
-		Use:   "build",
-		Short: "Build docker image",
-		Long:  `will build docker image for project`,
-		Run: func(cmd *cobra.Command, args []string) {
-			build()
-		},
+func build(ctx context.Context, c *config.Resolved, options BuildOptions) error {
+	if options.ChangedSince != "" {
+		changed, err := c.HasChangesSince(options.ChangedSince)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			log.Infof("No changes since %s, skipping build", options.ChangedSince)
+			return nil
+		}
 	}
 
-	cmdBuild.Flags().BoolVarP(&flag_rebuild, "rebuild", "r", false, "Force rebuild of image")
-	rootCmd.AddCommand(cmdBuild)
-}
+	image_name := c.GetProjectImage()
 
-func build() {
-	image_name := config.GetProjectImage()
+	exists := false
+	if !options.Rebuild {
+		var err error
+		if exists, err = utils.DockerImageExists(image_name); err != nil {
+			return err
+		}
+	}
 
-	if !flag_rebuild && utils.DockerImageExists(image_name) {
-		fmt.Printf("INFO: Docker image %s already exists\n", image_name)
+	if exists {
+		log.Infof("Docker image %s already exists", image_name)
+
+		if err := reportImage(options.Report, report.Image{Name: image_name}); err != nil {
+			return err
+		}
 
 		// Build test image if missing
-		if !utils.DockerImageExists(fmt.Sprintf("%s-test", image_name)) {
-			buildTest()
+		ref, err := c.GetProjectImageReference()
+		if err != nil {
+			return err
+		}
+		test_exists, err := utils.DockerImageExists(ref.WithTagSuffix("-test").String())
+		if err != nil {
+			return err
+		}
+		if !test_exists {
+			build_backend, err := getBuildBackend(ctx, c, options)
+			if err != nil {
+				return err
+			}
+			if err := buildTest(ctx, c, build_backend, options.Report); err != nil {
+				return err
+			}
 		}
 
-		os.Exit(0)
+		return tagImage(ctx, c)
 	}
 
-	if utils.FileExists("./Dockerfile.builder") {
-		buildBuilder()
-		buildTest()
-	} else if utils.FileExists("./Dockerfile") {
-		buildSimple()
-		buildTest()
+	if !utils.FileExists(filepath.Join(c.Dir(), "Dockerfile.builder")) && !utils.FileExists(filepath.Join(c.Dir(), "Dockerfile")) {
+		return ErrNoDockerfile
+	}
+
+	build_backend, err := getBuildBackend(ctx, c, options)
+	if err != nil {
+		return err
+	}
+
+	if utils.FileExists(filepath.Join(c.Dir(), "Dockerfile.builder")) {
+		if err := buildBuilder(ctx, c, build_backend, options.Report); err != nil {
+			return err
+		}
 	} else {
-		fmt.Printf("ERROR: %s\n", "No Dockerfile found.")
-		os.Exit(1)
+		if err := buildSimple(ctx, c, build_backend, options.Report); err != nil {
+			return err
+		}
+	}
+
+	if err := tagImage(ctx, c); err != nil {
+		return err
+	}
+
+	return buildTest(ctx, c, build_backend, options.Report)
+}
+
+// Load project of monorepo with the dependencies of the monorepo project
+func (p *Project) loadSubProject(c *config.Resolved, path string) (*config.Resolved, error) {
+	options := p.options
+	options.ConfigFile = ""
+
+	project, err := config.Load(filepath.Join(c.Dir(), path), options)
+	if err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("Unable to load project %s: %w", path, err)}
+	}
+	return project, nil
+}
+
+// Get projects of monorepo in build order. Every project is loaded to get
+// its image name, which is used to find dependencies through base images.
+func (p *Project) getProjects(c *config.Resolved, changed_since string) ([]config.SubProject, error) {
+	images := make(map[string]string)
+	for _, project := range c.GetSubProjects() {
+		sub, err := p.loadSubProject(c, project.Path)
+		if err != nil {
+			return nil, err
+		}
+		images[project.Path] = image.NormalizeName(fmt.Sprintf("%s/%s", sub.GetProjectOrganization(), sub.GetProjectName()))
+	}
+
+	return c.GetProjects(changed_since, images)
+}
+
+// Build projects of monorepo in dependency order, each with its own config
+func (p *Project) buildProjects(ctx context.Context, c *config.Resolved, options BuildOptions) error {
+	if len(options.CacheFrom) > 0 || len(options.CacheTo) > 0 {
+		return errors.New("Cache sources and destinations can't be given for a monorepo, set Cache in wrench.yml of projects")
+	}
+
+	projects, err := p.getProjects(c, options.ChangedSince)
+	if err != nil {
+		return err
+	}
+
+	if len(projects) == 0 {
+		log.Infof("No projects changed since %s", options.ChangedSince)
+		return nil
+	}
+
+	for _, project := range projects {
+		log.Infof("Building project %s", project.Path)
+
+		sub, err := p.loadSubProject(c, project.Path)
+		if err != nil {
+			return err
+		}
+
+		// Projects are selected already, they are built if missing
+		sub_options := BuildOptions{Rebuild: options.Rebuild, Ssh: options.Ssh, Secrets: options.Secrets, Report: options.Report}
+		if err := build(ctx, sub, sub_options); err != nil {
+			return fmt.Errorf("Build of project %s failed: %w", project.Path, err)
+		}
 	}
+
+	return nil
 }
 
-func buildBuilder() {
-	image_name := config.GetProjectImage()
+func buildBuilder(ctx context.Context, c *config.Resolved, build_backend *buildBackend, build_report *report.Report) error {
+	image_name := c.GetProjectImage()
 
-	builder_image_name := fmt.Sprintf("%s-builder", image_name)
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return err
+	}
+	builder_image_name := ref.WithTagSuffix("-builder").String()
 
-	fmt.Printf("INFO: %s %s\n\n",
-		"Found Dockerfile.builder, building image builder",
-		builder_image_name)
+	log.Infof("Found Dockerfile.builder, building image builder %s", builder_image_name)
 
 	// Build builder image
-	cmd_string := fmt.Sprintf("docker build -f Dockerfile.builder -t '%s' .", builder_image_name)
-	cmd := exec.Command("sh", "-c", cmd_string)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	dockerfile, err := utils.GetFileContent(filepath.Join(c.Dir(), "Dockerfile.builder"))
+	if err != nil {
+		return err
+	}
+
+	secret_args, env, err := getBuildSecrets(c, dockerfile)
+	if err != nil {
+		return err
+	}
 
-	version := strings.TrimLeft(config.GetProjectVersion(), "v")
-	fmt.Printf("INFO: Adding env variable VERSION=%s\n\n", version)
-	utils.DockerImageAddEnv(builder_image_name, "VERSION", version)
+	build_cmd, build_env := build_backend.command("-builder")
+
+	start := time.Now()
+	cmd_string := fmt.Sprintf("%s -f Dockerfile.builder -t '%s' %s .", build_cmd, builder_image_name, secret_args)
+	cache_hits, err := runBuildCommand(ctx, c, cmd_string, append(env, build_env...))
+
+	version := strings.TrimLeft(c.GetProjectVersion(), "v")
+	log.Debugf("Adding env variable VERSION=%s", version)
+	if env_err := utils.DockerImageAddEnv(ctx, builder_image_name, "VERSION", version); err == nil {
+		err = env_err
+	}
 
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
+	}
+
+	if err := reportImage(build_report, report.Image{Name: builder_image_name, Built: true, Duration: report.Seconds(time.Since(start)), CacheHits: cache_hits}); err != nil {
+		return err
 	}
 
-	fmt.Printf("\nINFO: %s %s\n\n",
-		"Building image with builder",
-		image_name)
+	log.Infof("Building image with builder %s", image_name)
 
 	// Build image
-	cmd_string = fmt.Sprintf("docker run --rm '%s' | docker build -t '%s' -", builder_image_name, image_name)
-	cmd = exec.Command("sh", "-c", cmd_string)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
+	build_cmd, build_env = build_backend.command("")
+
+	start = time.Now()
+	counter := &cacheCounter{}
+	cmd_string = fmt.Sprintf("docker run --rm '%s' | %s -t '%s' -", builder_image_name, build_cmd, image_name)
+	log.Debugf("%s", cmd_string)
+	cmd := utils.ShellCommandContext(ctx, cmd_string)
+	cmd.Dir = c.Dir()
+	cmd.Env = append(os.Environ(), build_env...)
+	cmd.Stdout = io.MultiWriter(os.Stdout, counter)
+	cmd.Stderr = io.MultiWriter(os.Stderr, counter)
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Command: "docker build", ExitCode: utils.GetCommandExitCode(err)}
+	}
+
+	log.Debugf("Adding env variable VERSION=%s", version)
+	if err := utils.DockerImageAddEnv(ctx, image_name, "VERSION", version); err != nil {
+		return err
+	}
+
+	return reportImage(build_report, report.Image{Name: image_name, Built: true, Duration: report.Seconds(time.Since(start)), CacheHits: counter.Hits()})
+}
 
+func buildSimple(ctx context.Context, c *config.Resolved, build_backend *buildBackend, build_report *report.Report) error {
+	image_name := c.GetProjectImage()
+
+	log.Infof("Found Dockerfile, building image %s", image_name)
+
+	dockerfile, err := utils.GetFileContent(filepath.Join(c.Dir(), "Dockerfile"))
+	if err != nil {
+		return err
+	}
+
+	secret_args, env, err := getBuildSecrets(c, dockerfile)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
+	}
+
+	build_cmd, build_env := build_backend.command("")
+
+	start := time.Now()
+	cmd_string := fmt.Sprintf("%s -t '%s' %s .", build_cmd, image_name, secret_args)
+	cache_hits, err := runBuildCommand(ctx, c, cmd_string, append(env, build_env...))
+	if err != nil {
+		return err
+	}
+
+	version := strings.TrimLeft(c.GetProjectVersion(), "v")
+	log.Debugf("Adding env variable VERSION=%s", version)
+	if err := utils.DockerImageAddEnv(ctx, image_name, "VERSION", version); err != nil {
+		return err
 	}
 
-	fmt.Printf("INFO: Adding env variable VERSION=%s\n\n", version)
-	utils.DockerImageAddEnv(image_name, "VERSION", version)
+	return reportImage(build_report, report.Image{Name: image_name, Built: true, Duration: report.Seconds(time.Since(start)), CacheHits: cache_hits})
 }
 
-func buildSimple() {
-	image_name := config.GetProjectImage()
+func buildTest(ctx context.Context, c *config.Resolved, build_backend *buildBackend, build_report *report.Report) error {
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return err
+	}
+
+	test_image_name := ref.WithTagSuffix("-test").String()
+
+	if !utils.FileExists(filepath.Join(c.Dir(), "Dockerfile.test")) {
+		return nil
+	}
 
-	fmt.Printf("INFO: %s %s\n\n",
-		"Found Dockerfile, building image",
-		image_name)
+	log.Infof("Found Dockerfile.test, building test image %s", test_image_name)
 
-	cmd_string := fmt.Sprintf("docker build -t '%s' .", image_name)
-	cmd := exec.Command("sh", "-c", cmd_string)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	dockerfile, err := utils.GetFileContent(filepath.Join(c.Dir(), "Dockerfile.test"))
+	if err != nil {
+		return err
+	}
+
+	builder := ""
+	if utils.FileExists(filepath.Join(c.Dir(), "Dockerfile.builder")) {
+		builder = ref.WithTagSuffix("-builder").String()
+	}
 
+	temp_dockerfile_content, build_args, err := renderTestDockerfile(dockerfile, ref.String(), builder)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
 
-	version := strings.TrimLeft(config.GetProjectVersion(), "v")
-	fmt.Printf("INFO: Adding env variable VERSION=%s\n\n", version)
-	utils.DockerImageAddEnv(image_name, "VERSION", version)
+	// Tempdir for building test image
+	tempdir, err := ioutil.TempDir(c.Dir(), ".wrench_build_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	temp_dockerfile := fmt.Sprintf("%s/Dockerfile.test", tempdir)
+	if err := utils.WriteFileContent(temp_dockerfile, temp_dockerfile_content); err != nil {
+		return err
+	}
+
+	secret_args, env, err := getBuildSecrets(c, temp_dockerfile_content)
+	if err != nil {
+		return err
+	}
+
+	build_cmd, build_env := build_backend.command("-test")
+
+	start := time.Now()
+	cmd_string := fmt.Sprintf("%s -f %s -t '%s' %s %s .", build_cmd, temp_dockerfile, test_image_name, utils.ShellQuoteArgs(build_args), secret_args)
+	cache_hits, err := runBuildCommand(ctx, c, cmd_string, append(env, build_env...))
+	if err != nil {
+		return err
+	}
+
+	return reportImage(build_report, report.Image{Name: test_image_name, Built: true, Duration: report.Seconds(time.Since(start)), CacheHits: cache_hits})
 }
 
-func buildTest() {
-	image_name := config.GetProjectImage()
+// Build args with the images Dockerfile.test is based on
+const testImageArg = "WRENCH_IMAGE"
+const testBuilderArg = "WRENCH_BUILDER"
+
+// Placeholders of base images in FROM of Dockerfile.test by build arg
+var testPlaceholders = map[string]string{"{{image}}": testImageArg, "{{builder}}": testBuilderArg}
 
-	test_image_name := fmt.Sprintf("%s-test", image_name)
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([a-zA-Z]*)\s*\}\}`)
 
-	if !utils.FileExists("./Dockerfile.test") {
-		return
+// Check if args contain {{image}} or {{builder}}. Other templates, like
+// mustache or Go templates written to files by RUN, are not placeholders.
+// Heredoc content is not part of args.
+func hasTestPlaceholder(args string) bool {
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(args, -1) {
+		if _, ok := testPlaceholders[fmt.Sprintf("{{%s}}", match[1])]; ok {
+			return true
+		}
 	}
+	return false
+}
 
-	fmt.Printf("INFO: %s %s\n\n",
-		"Found Dockerfile.test, building test image",
-		test_image_name)
+// Render Dockerfile.test with base images replaced by build args, and get
+// the build args. FROM {{image}} and FROM {{builder}} are based on image
+// and builder in any stage. Without placeholders the base of the first
+// stage is replaced, by builder if it ends with builder like FROM builder.
+// Builder is empty if project has no builder image.
+func renderTestDockerfile(content string, image string, builder string) (string, []string, error) {
+	d, err := dockerfile.Parse(content)
+	if err != nil {
+		return "", nil, errors.New(fmt.Sprintf("Invalid Dockerfile.test: %s", err))
+	}
 
-	dockerfile := utils.GetFileContent("./Dockerfile.test")
+	placeholders := false
+	for _, instruction := range d.Instructions {
+		if instruction.Command == "FROM" && strings.Contains(instruction.Args, "{{") {
+			placeholders = true
+		}
+	}
+
+	used := map[string]bool{}
+	stages := 0
+	for _, instruction := range d.Instructions {
+		if instruction.Command != "FROM" {
+			if stages == 0 && instruction.Command != "ARG" {
+				return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: %s before first FROM, only ARG is allowed", instruction.Line, instruction.Command))
+			}
+			if hasTestPlaceholder(instruction.Args) {
+				return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: placeholders are only supported as base image in FROM", instruction.Line))
+			}
+			continue
+		}
+		stages += 1
+
+		from, err := dockerfile.ParseFrom(placeholderRegexp.ReplaceAllString(instruction.Args, "{{$1}}"))
+		if err != nil {
+			return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: %s", instruction.Line, err))
+		}
 
-	dockerfile_lines := strings.Split(dockerfile, "\n")
+		arg := ""
+		switch {
+		case placeholders && strings.Contains(from.Image, "{{"):
+			var ok bool
+			if arg, ok = testPlaceholders[from.Image]; !ok {
+				return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: unknown placeholder %s, use {{image}} or {{builder}}", instruction.Line, from.Image))
+			}
+		case placeholders && from.Image == "":
+			return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: FROM without base image, use FROM {{image}} or FROM {{builder}}", instruction.Line))
+		case !placeholders && stages == 1 && strings.HasSuffix(from.Image, "builder"):
+			arg = testBuilderArg
+		case !placeholders && stages == 1:
+			arg = testImageArg
+		}
+		if arg == "" {
+			continue
+		}
 
-	if !strings.HasPrefix(dockerfile_lines[0], "FROM") {
-		fmt.Println("ERROR: Missing FROM on first line in Dockerfile.test")
-		os.Exit(1)
+		if arg == testBuilderArg && builder == "" {
+			return "", nil, errors.New(fmt.Sprintf("Dockerfile.test line %d: based on builder image, but project has no Dockerfile.builder", instruction.Line))
+		}
+
+		from.Image = fmt.Sprintf("${%s}", arg)
+		d.Replace(instruction, from.String())
+		used[arg] = true
 	}
 
-	// if FROM string subfix with builder then base on builder image
-	if strings.HasSuffix(dockerfile_lines[0], "builder") {
-		dockerfile_lines[0] = fmt.Sprintf("FROM %s-builder", image_name)
-	} else {
-		dockerfile_lines[0] = fmt.Sprintf("FROM %s", image_name)
+	if stages == 0 {
+		return "", nil, errors.New("Dockerfile.test has no FROM instruction")
 	}
 
-	temp_dockerfile_content := strings.Join(dockerfile_lines, "\n")
+	// Args declared before the first FROM can be used in FROM
+	var declarations []string
+	var build_args []string
+	for _, arg := range []string{testImageArg, testBuilderArg} {
+		if !used[arg] {
+			continue
+		}
+		value := image
+		if arg == testBuilderArg {
+			value = builder
+		}
+		declarations = append(declarations, "ARG "+arg)
+		build_args = append(build_args, "--build-arg", fmt.Sprintf("%s=%s", arg, value))
+	}
+	d.InsertFirst(declarations...)
 
-	// Tempdir for building test image
-	dir, err := os.Getwd()
+	return d.String(), build_args, nil
+}
+
+// Tag image with every tag from TagTemplates in wrench.yml
+func tagImage(ctx context.Context, c *config.Resolved) error {
+	ref, err := c.GetProjectImageReference()
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	tempdir, err := ioutil.TempDir(dir, ".wrench_build_")
+	tags, err := c.GetImageTags(c.GetProjectVersion())
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
-	defer os.RemoveAll(tempdir)
 
-	temp_dockerfile := fmt.Sprintf("%s/Dockerfile.test", tempdir)
-	utils.WriteFileContent(temp_dockerfile, temp_dockerfile_content)
+	for _, tag := range tags {
+		tagged := ref.WithTag(tag)
+		if tagged == ref {
+			continue
+		}
 
-	cmd_string := fmt.Sprintf("docker build -f %s -t '%s' .", temp_dockerfile, test_image_name)
-	cmd := exec.Command("sh", "-c", cmd_string)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
+		log.Infof("Tagging image %s", tagged)
+		if exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("docker tag %s %s", ref, tagged)); exitcode != 0 {
+			return &CommandError{Command: "docker tag", ExitCode: exitcode, Output: out}
+		}
+	}
+
+	return nil
+}
+
+// Get docker build arguments and environment for secrets in wrench.yml
+// mounted in dockerfile. Secrets are passed as BuildKit secret mounts
+// through the environment of the docker client.
+func getBuildSecrets(c *config.Resolved, dockerfile string) (string, []string, error) {
+	env := os.Environ()
+
+	var names []string
+	for _, id := range secrets.GetDockerfileSecretIds(dockerfile) {
+		if _, ok := c.GetSecret(id); ok {
+			names = append(names, id)
+		}
+	}
+	if len(names) == 0 {
+		return "", env, nil
+	}
+
+	secret_env, err := secrets.ResolveEnv(c, names)
+	if err != nil {
+		return "", env, err
+	}
+	env = append(env, secret_env...)
+
+	// Secret mounts requires BuildKit
+	env = append(env, "DOCKER_BUILDKIT=1")
+
+	var args []string
+	for _, name := range names {
+		args = append(args, "--secret", fmt.Sprintf("id=%s,env=%s", name, name))
+	}
+
+	return utils.ShellQuoteArgs(args), env, nil
+}
+
+// Run docker build command in project directory with output masking
+// resolved secrets. Returns number of build steps taken from cache.
+func runBuildCommand(ctx context.Context, c *config.Resolved, cmd_string string, env []string) (int, error) {
+	stdout := secrets.NewMaskWriter(os.Stdout)
+	stderr := secrets.NewMaskWriter(os.Stderr)
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	counter := &cacheCounter{}
+
+	log.Debugf("%s", secrets.Mask(cmd_string))
+	cmd := utils.ShellCommandContext(ctx, cmd_string)
+	cmd.Dir = c.Dir()
+	cmd.Env = env
+	cmd.Stdout = io.MultiWriter(stdout, counter)
+	cmd.Stderr = io.MultiWriter(stderr, counter)
+	if err := cmd.Run(); err != nil {
+		// Output of docker build has already been written
+		return counter.Hits(), &CommandError{Command: "docker build", ExitCode: utils.GetCommandExitCode(err)}
+	}
+
+	return counter.Hits(), nil
+}
 
+// Add image to report with ID, size and digest of local image, nothing is
+// done if no report is requested
+func reportImage(build_report *report.Report, image report.Image) error {
+	if build_report == nil {
+		return nil
+	}
+
+	info, err := utils.DockerInspectImage(image.Name)
 	if err != nil {
-		os.RemoveAll(tempdir)
-		fmt.Println(err)
-		os.Exit(1)
+		return errors.New(fmt.Sprintf("Unable to inspect image %s: %s", image.Name, err))
+	}
+
+	image.ID = info.ID
+	image.Size = info.Size
+	if len(info.RepoDigests) > 0 {
+		image.Digest = info.RepoDigests[0][strings.LastIndex(info.RepoDigests[0], "@")+1:]
+	}
+
+	build_report.AddImage(image)
+	return nil
+}
+
+// Writer counting build steps taken from cache in docker build output,
+// "Using cache" of the classic builder and "CACHED" of BuildKit
+type cacheCounter struct {
+	mutex sync.Mutex
+	line  []byte
+	hits  int
+}
+
+func (c *cacheCounter) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, b := range p {
+		if b != '\n' {
+			c.line = append(c.line, b)
+			continue
+		}
+		if isCacheHit(string(c.line)) {
+			c.hits += 1
+		}
+		c.line = c.line[:0]
+	}
+
+	return len(p), nil
+}
+
+// Get number of cache hits, including an unterminated last line
+func (c *cacheCounter) Hits() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if isCacheHit(string(c.line)) {
+		return c.hits + 1
 	}
+	return c.hits
+}
+
+func isCacheHit(line string) bool {
+	return strings.Contains(line, "---> Using cache") || strings.Contains(line, " CACHED")
 }
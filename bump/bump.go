@@ -1,124 +1,125 @@
 package bump
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/errdefs"
 	"github.com/tomologic/wrench/semver"
 	"github.com/tomologic/wrench/utils"
-
-	"github.com/spf13/cobra"
 )
 
-func AddToWrench(rootCmd *cobra.Command) {
-	var cmdBump = &cobra.Command{
-		Use:   "bump [major,minor,patch]",
-		Short: "Bump project version",
-		Long:  `will bump project version, tag git tree and tag snapshot docker image`,
-		Run: func(cmd *cobra.Command, args []string) {
-			var err error
-			if len(args) == 0 {
-				err = bump("minor")
-			} else if len(args) == 1 {
-				err = bump(args[0])
-			} else {
-				cmd.Usage()
-				os.Exit(1)
-			}
-
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-		},
-	}
-
-	rootCmd.AddCommand(cmdBump)
-}
+// Bump project version by level major, minor or patch. Tags git tree and
+// snapshot docker image and returns the release version. If the revision
+// is already released ErrAlreadyReleased is returned with that version.
+func Bump(ctx context.Context, c *config.Resolved, level string) (string, error) {
+	strategy := c.GetVersioning().Strategy
 
-func bump(level string) error {
-	version, err := semver.Parse(config.GetProjectVersion())
+	version, err := semver.ParseStrategy(strategy, c.GetProjectVersion())
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Make sure version is snapshot version
 	if version.IsReleaseVersion() {
-		fmt.Printf("Revision already release '%s'. Doing nothing.\n", version.String())
-		os.Exit(0)
+		return version.String(), errdefs.ErrAlreadyReleased
 	}
 
 	// Create new release version
-	if err = version.Bump(level); err != nil {
-		return err
+	if err = version.BumpStrategy(strategy, level, time.Now()); err != nil {
+		return "", err
+	}
+
+	release, err := semver.FormatRelease(strategy, version)
+	if err != nil {
+		return "", err
 	}
 
 	// Make sure docker image of current snapshot version exists
-	image_name, err := getImageName()
+	image_name, err := getImageName(c)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// create git tag
-	if exitcode, out := utils.RunCmd(fmt.Sprintf("git tag -a %s -m 'Release %s'", version.String(), version.String())); exitcode != 0 {
-		return errors.New(fmt.Sprintf("git tag exited with %d: %s\n", exitcode, out))
+	// create git tag with tag prefix of project
+	tag := c.GetTagName(version)
+	if exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("git -C %s tag -a %s -m 'Release %s'", utils.ShellQuote(c.Dir()), tag, tag)); exitcode != 0 {
+		return "", &errdefs.CommandError{Command: "git tag", ExitCode: exitcode, Output: out}
 	}
 
+	// Remove git tag again if release image isn't created, like when bump
+	// is interrupted, so bump can be run again
+	released := false
+	defer func() {
+		if !released {
+			utils.RunCmd(fmt.Sprintf("git -C %s tag -d %s", utils.ShellQuote(c.Dir()), tag))
+		}
+	}()
+
 	// create image
-	new_image_name := fmt.Sprintf("%s/%s:%s", config.GetProjectOrganization(), config.GetProjectName(), version.String())
-	exitcode, out := utils.RunCmd(fmt.Sprintf("docker tag %s %s", image_name, new_image_name))
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return "", err
+	}
+	new_image_name := ref.WithTag(release).String()
+	exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("docker tag %s %s", image_name, new_image_name))
 
 	if exitcode != 0 {
-		return errors.New(fmt.Sprintf("docker tag exited with %d: %s\n", exitcode, out))
+		return "", &errdefs.CommandError{Command: "docker tag", ExitCode: exitcode, Output: out}
 	}
 
-	ver := strings.TrimLeft(version.String(), "v")
-	if err := utils.DockerImageAddEnv(new_image_name, "VERSION", ver); err != nil {
+	ver := strings.TrimLeft(release, "v")
+	if err := utils.DockerImageAddEnv(ctx, new_image_name, "VERSION", ver); err != nil {
 		// remove image which is unfinished
 		utils.DockerRemoveImage(new_image_name)
 
-		return errors.New("Failed updating VERSION env")
+		return "", errors.New("Failed updating VERSION env")
 	}
+	released = true
 
-	fmt.Printf("Released %s\n", version.String())
+	// tag release image with tags from TagTemplates
+	tags, err := c.GetImageTags(release)
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range tags {
+		if tagged := ref.WithTag(tag); tagged != ref {
+			if exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("docker tag %s %s", new_image_name, tagged)); exitcode != 0 {
+				return "", &errdefs.CommandError{Command: "docker tag", ExitCode: exitcode, Output: out}
+			}
+		}
+	}
 
-	return nil
+	return release, nil
 }
 
-func getImageName() (string, error) {
-	git_short, err := getGitShortSha()
+func getImageName(c *config.Resolved) (string, error) {
+	git_short, err := c.Git().ShortSha()
 	if err != nil {
 		return "", err
 	}
 
-	tags, err := getGitSemverTags()
+	versions, err := c.GetVersionTags()
 	if err != nil {
 		return "", err
 	}
 
 	// Sort tags with semver lib
-	var versions semver.SemverList
-	for _, tag := range tags {
-		if ver, err := semver.Parse(tag); err != nil {
-			return "", err
-		} else {
-			versions = append(versions, ver)
-		}
-	}
 	sort.Sort(versions)
 
 	// Iterate over all tags
 	for _, version := range versions {
-		// Convert version back to string
-		tag := version.String()
+		// Convert version back to tag name
+		tag := c.GetTagName(version)
 
 		// Calculate commit count since tag
-		num_commits, err := getGitCommitCountSince(tag)
+		num_commits, err := getGitCommitCountSince(c, tag)
 		if err != nil {
 			return "", err
 		}
@@ -128,44 +129,56 @@ func getImageName() (string, error) {
 			continue
 		}
 
-		// generate snapshot version
-		version := fmt.Sprintf("%s-%d-g%s", tag, num_commits, git_short)
+		// generate snapshot version with versioning strategy of project
+		version, err := c.FormatVersion(semver.Revision{Tag: version, Commits: num_commits, Sha: git_short})
+		if err != nil {
+			return "", err
+		}
 
 		// generate image name
-		image_name := fmt.Sprintf("%s/%s:%s",
-			config.GetProjectOrganization(),
-			config.GetProjectName(),
-			version)
+		ref, err := c.GetProjectImageReference()
+		if err != nil {
+			return "", err
+		}
+		image_name := ref.WithTag(version).String()
 
 		// check if image for this snapshot version exists
-		if utils.DockerImageExists(image_name) {
+		if exists, err := utils.DockerImageExists(image_name); err != nil {
+			return "", err
+		} else if exists {
 			return image_name, nil
 		}
 	}
 
 	// check if image for this snapshot version exists based on a root commit
-	roots, err := getRootCommits()
+	roots, err := getRootCommits(c)
 	if err != nil {
 		return "", err
 	}
 	for _, sha := range roots {
 		// Calculate commit count since root
-		num_commits, err := getGitCommitCountSince(sha)
+		num_commits, err := getGitCommitCountSince(c, sha)
 		if err != nil {
 			return "", err
 		}
 
-		// generate snapshot version
-		version := fmt.Sprintf("v0.0.0-%d-g%s", num_commits, git_short)
+		// generate snapshot version with versioning strategy of project
+		version, err := c.FormatVersion(semver.Revision{Commits: num_commits, Sha: git_short})
+		if err != nil {
+			return "", err
+		}
 
 		// generate image name
-		image_name := fmt.Sprintf("%s/%s:%s",
-			config.GetProjectOrganization(),
-			config.GetProjectName(),
-			version)
+		ref, err := c.GetProjectImageReference()
+		if err != nil {
+			return "", err
+		}
+		image_name := ref.WithTag(version).String()
 
 		// check if image for this snapshot version exists
-		if utils.DockerImageExists(image_name) {
+		if exists, err := utils.DockerImageExists(image_name); err != nil {
+			return "", err
+		} else if exists {
 			return image_name, nil
 		}
 	}
@@ -174,23 +187,8 @@ func getImageName() (string, error) {
 	return "", errors.New(fmt.Sprintf("Docker image for revision %s could not be found", git_short))
 }
 
-func getGitSemverTags() ([]string, error) {
-	exitcode, out := utils.RunCmd("git tag -l 'v[0-9]*\\.[0-9]*\\.[0-9]*'")
-	if exitcode != 0 {
-		return nil, errors.New(fmt.Sprintf("%d: %s", exitcode, out))
-	}
-
-	// Split lines into slice
-	tags := strings.Split(out, "\n")
-
-	// Remove empty rows
-	tags = utils.RemoveEmptyStrings(tags)
-
-	return tags, nil
-}
-
-func getRootCommits() ([]string, error) {
-	exitcode, out := utils.RunCmd("git rev-list --max-parents=0 HEAD")
+func getRootCommits(c *config.Resolved) ([]string, error) {
+	exitcode, out := utils.RunCmd(fmt.Sprintf("git -C %s rev-list --max-parents=0 HEAD", utils.ShellQuote(c.Dir())))
 	if exitcode != 0 {
 		return nil, errors.New(fmt.Sprintf("%d: %s", exitcode, out))
 	}
@@ -204,8 +202,8 @@ func getRootCommits() ([]string, error) {
 	return roots, nil
 }
 
-func getGitCommitCountSince(sha string) (int, error) {
-	exitcode, out := utils.RunCmd(fmt.Sprintf("git rev-list %s..HEAD --count", sha))
+func getGitCommitCountSince(c *config.Resolved, sha string) (int, error) {
+	exitcode, out := utils.RunCmd(fmt.Sprintf("git -C %s rev-list %s..HEAD --count", utils.ShellQuote(c.Dir()), sha))
 	if exitcode != 0 {
 		return 0, errors.New(out)
 	}
@@ -217,15 +215,3 @@ func getGitCommitCountSince(sha string) (int, error) {
 
 	return num, nil
 }
-
-func getGitShortSha() (string, error) {
-	exitcode, out := utils.RunCmd("git rev-parse --short HEAD")
-	if exitcode == 128 {
-		return "", errors.New("No semver formatted git tag found")
-	} else if exitcode != 0 {
-		return "", errors.New(out)
-	} else if out == "" {
-		return "", errors.New("Empty output from git rev-parse")
-	}
-	return strings.TrimSpace(string(out)), nil
-}
@@ -0,0 +1,205 @@
+package wrench
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/semver"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Cache sources and destinations of docker builds of project
+type buildCache struct {
+	from []config.CacheSpec
+	to   []config.CacheSpec
+
+	// Previous images of project used as cache sources
+	previous []image.Reference
+
+	// Add inline cache metadata to images, so they can be cache sources
+	// of later builds. Only set when a cache is used, since it needs
+	// BuildKit.
+	inline bool
+}
+
+// Get build cache of project from Cache in wrench.yml, the cache options
+// and previous images of project
+func getBuildCache(c *config.Resolved, options BuildOptions) (*buildCache, error) {
+	from, err := c.GetCacheSpecs(false, options.CacheFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := c.GetCacheSpecs(true, options.CacheTo)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &buildCache{from: from, to: to}
+
+	if c.UsePreviousImageCache() {
+		if cache.previous, err = getPreviousImages(c); err != nil {
+			return nil, err
+		}
+	}
+
+	cache.inline = len(from) > 0 || len(to) > 0 || len(cache.previous) > 0
+
+	for _, ref := range cache.previous {
+		log.Debugf("Using previous image %s as build cache", ref)
+	}
+
+	return cache, nil
+}
+
+// Get newest local image of another version of project and image of latest
+// release to seed build cache. Release image is only used if it exists
+// locally or is in a registry, and not without a git repository or tags.
+func getPreviousImages(c *config.Resolved) ([]image.Reference, error) {
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return nil, err
+	}
+	strategy := c.GetVersioning().Strategy
+
+	tags, err := utils.DockerListImageTags(ref.Name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to list images of %s: %s", ref.Name, err))
+	}
+
+	var previous []image.Reference
+
+	if newest := selectPreviousImage(tags, strategy, ref.Tag); newest != "" {
+		if newest_ref, err := image.Parse(newest); err == nil {
+			previous = append(previous, newest_ref)
+		}
+	}
+
+	release, err := getLatestRelease(c)
+	if err != nil {
+		log.Debugf("No release image used as build cache: %s", err)
+		return previous, nil
+	}
+	if release == "" {
+		return previous, nil
+	}
+
+	release_ref := ref.WithTag(release)
+	if release_ref == ref {
+		return previous, nil
+	}
+	for _, p := range previous {
+		if p == release_ref {
+			return previous, nil
+		}
+	}
+
+	exists, err := utils.DockerImageExists(release_ref.String())
+	if err != nil {
+		return nil, err
+	}
+	if exists || release_ref.Registry() != "" {
+		previous = append(previous, release_ref)
+	}
+
+	return previous, nil
+}
+
+// Get latest release version formatted with versioning strategy, empty if
+// there are no version tags
+func getLatestRelease(c *config.Resolved) (string, error) {
+	if present, err := c.Git().Present(); !present {
+		return "", err
+	}
+
+	versions, err := c.GetVersionTags()
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+	sort.Sort(versions)
+
+	return semver.FormatRelease(c.GetVersioning().Strategy, versions[len(versions)-1])
+}
+
+// Get name of newest image tagged with a version of strategy other than
+// current. Test, builder and run images are not versions of project.
+func selectPreviousImage(tags []utils.DockerImageTag, strategy string, current string) string {
+	var newest *utils.DockerImageTag
+
+	for i, tag := range tags {
+		version := tag.Name[strings.LastIndex(tag.Name, ":")+1:]
+
+		if version == current || strings.Contains(version, ".wrench_run_") ||
+			strings.HasSuffix(version, "-test") || strings.HasSuffix(version, "-builder") {
+			continue
+		}
+		if _, err := semver.ParseStrategyTag(strategy, version); err != nil {
+			continue
+		}
+
+		if newest == nil || tag.Created.After(newest.Created) {
+			newest = &tags[i]
+		}
+	}
+
+	if newest == nil {
+		return ""
+	}
+	return newest.Name
+}
+
+// Check if buildx is needed, the classic docker build only imports cache
+// from images and exports inline cache
+func (b *buildCache) needsBuildx() bool {
+	if len(b.to) > 0 {
+		return true
+	}
+	for _, spec := range b.from {
+		if spec.Type != "registry" {
+			return true
+		}
+	}
+	return false
+}
+
+// Get docker build cache arguments for image with tag suffix, like
+// -builder or -test, as BuildKit cache specs for buildx or images for the
+// classic docker build
+func (b *buildCache) args(suffix string, buildx bool) []string {
+	var args []string
+
+	for _, spec := range b.from {
+		if buildx {
+			args = append(args, "--cache-from", spec.WithSuffix(suffix).String())
+		} else {
+			args = append(args, "--cache-from", spec.WithSuffix(suffix).Attrs["ref"])
+		}
+	}
+	for _, ref := range b.previous {
+		if buildx {
+			spec := config.CacheSpec{Type: "registry", Attrs: map[string]string{"ref": ref.WithTagSuffix(suffix).String()}}
+			args = append(args, "--cache-from", spec.String())
+		} else {
+			args = append(args, "--cache-from", ref.WithTagSuffix(suffix).String())
+		}
+	}
+	for _, spec := range b.to {
+		args = append(args, "--cache-to", spec.WithSuffix(suffix).String())
+	}
+
+	// Inline cache is exported in addition to cache destinations only by
+	// recent BuildKit versions
+	if b.inline && len(b.to) == 0 {
+		args = append(args, "--build-arg", "BUILDKIT_INLINE_CACHE=1")
+	}
+
+	return args
+}
@@ -0,0 +1,362 @@
+// Package clean removes what wrench leaves behind when it is interrupted
+// before it has cleaned up, and snapshot images no longer needed.
+package clean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/semver"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Prefixes of temp dirs created in project directory by run and build
+var tempPrefixes = []string{".wrench_run_", ".wrench_build_"}
+
+// Part of tag of temporary run images, <image>-.wrench_run_*
+const runImageTag = "-.wrench_run_"
+
+// Minimum age of temp dirs and run images removed by the clean command
+const DefaultMinAge = time.Hour
+
+type Options struct {
+	// Only list what would be removed
+	DryRun bool
+
+	// Keep temp dirs, files and run images created more recently, they may
+	// be used by wrench running in the project. Runs with a running
+	// container are always kept.
+	MinAge time.Duration
+
+	// Remove snapshot images created longer ago, none if zero
+	SnapshotsOlderThan time.Duration
+
+	// Remove snapshot images of all but the newest snapshot versions, none
+	// if zero
+	KeepSnapshots int
+}
+
+// Resource removed by clean
+type Item struct {
+	// dir, file, container, network or image
+	Kind string
+	Name string
+}
+
+func (i Item) String() string {
+	return fmt.Sprintf("%s %s", i.Kind, i.Name)
+}
+
+// Remove temp dirs and files of runs and builds, containers, networks and
+// images of runs, and snapshot images selected by options. Images of the
+// current project version are never removed. Returns items removed, or
+// items that would be removed on dry run.
+func Clean(ctx context.Context, c *config.Resolved, options Options) ([]Item, error) {
+	now := time.Now()
+
+	items, active, err := getTempItems(ctx, c.Dir(), options.MinAge, now)
+	if err != nil {
+		return nil, err
+	}
+
+	image_items, err := getImageItems(c, options, active, now)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, image_items...)
+
+	if options.DryRun {
+		return items, nil
+	}
+
+	// Containers are removed before networks and images they use
+	sort.SliceStable(items, func(i, j int) bool {
+		return kindOrder(items[i].Kind) < kindOrder(items[j].Kind)
+	})
+
+	var removed []Item
+	failed := 0
+	for _, item := range items {
+		if err := remove(ctx, c.Dir(), item); err != nil {
+			log.Warningf("Unable to remove %s: %s", item, err)
+			failed += 1
+			continue
+		}
+		removed = append(removed, item)
+	}
+
+	if failed > 0 {
+		return removed, errors.New(fmt.Sprintf("Unable to remove %d of %d items", failed, len(items)))
+	}
+	return removed, nil
+}
+
+func kindOrder(kind string) int {
+	switch kind {
+	case "container":
+		return 0
+	case "network":
+		return 1
+	case "image":
+		return 2
+	}
+	return 3
+}
+
+// Get temp dirs and files in dir older than min_age, and containers and
+// networks of runs whose temp dir was left behind. Runs with a temp dir
+// that is too recent or a running container are in use, their names are
+// returned as active.
+func getTempItems(ctx context.Context, dir string, min_age time.Duration, now time.Time) ([]Item, map[string]bool, error) {
+	var items []Item
+	active := map[string]bool{}
+
+	for _, prefix := range tempPrefixes {
+		matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Run container, service containers and network are named
+			// after the temp dir
+			run_name := strings.TrimLeft(filepath.Base(match), ".")
+			is_run := info.IsDir() && prefix == ".wrench_run_"
+
+			if now.Sub(info.ModTime()) < min_age {
+				log.Debugf("Keeping %s, created less than %s ago", filepath.Base(match), min_age)
+				if is_run {
+					active[run_name] = true
+				}
+				continue
+			}
+
+			if !is_run {
+				kind := "dir"
+				if !info.IsDir() {
+					kind = "file"
+				}
+				items = append(items, Item{Kind: kind, Name: filepath.Base(match)})
+				continue
+			}
+
+			containers, running, err := listRunContainers(ctx, run_name)
+			if err != nil {
+				return nil, nil, err
+			}
+			if running {
+				log.Debugf("Keeping %s, its container is running", filepath.Base(match))
+				active[run_name] = true
+				continue
+			}
+
+			items = append(items, Item{Kind: "dir", Name: filepath.Base(match)})
+			for _, container := range containers {
+				items = append(items, Item{Kind: "container", Name: container})
+			}
+
+			networks, err := listDocker(ctx, fmt.Sprintf("docker network ls --filter 'name=^%s$' --format '{{.Name}}'", run_name))
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, network := range networks {
+				if network == run_name {
+					items = append(items, Item{Kind: "network", Name: network})
+				}
+			}
+		}
+	}
+
+	return items, active, nil
+}
+
+// Get containers of run, the run container and its service containers,
+// and if any of them is running
+func listRunContainers(ctx context.Context, run_name string) ([]string, bool, error) {
+	// Name filter is a regular expression, container names may be matched
+	// with their leading slash
+	lines, err := listDocker(ctx, fmt.Sprintf("docker ps -a --filter 'name=^/?%s(-.+)?$' --format '{{.Names}} {{.State}}'", run_name))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var containers []string
+	running := false
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !isRunContainer(fields[0], run_name) {
+			continue
+		}
+		containers = append(containers, fields[0])
+		if fields[1] == "running" {
+			running = true
+		}
+	}
+
+	return containers, running, nil
+}
+
+// Check if container belongs to run, named after it or after it and a
+// service
+func isRunContainer(name string, run_name string) bool {
+	return name == run_name || strings.HasPrefix(name, run_name+"-")
+}
+
+// Run docker command listing names, one per line
+func listDocker(ctx context.Context, command string) ([]string, error) {
+	exitcode, out := utils.RunCmdContext(ctx, command)
+	if exitcode != 0 {
+		return nil, errors.New(fmt.Sprintf("%s exited with %d: %s", strings.Fields(command)[1], exitcode, out))
+	}
+	return utils.RemoveEmptyStrings(strings.Split(out, "\n")), nil
+}
+
+// Get run images and snapshot images of project to remove. Run images of
+// active runs or created less than options.MinAge ago are kept.
+func getImageItems(c *config.Resolved, options Options, active map[string]bool, now time.Time) ([]Item, error) {
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := utils.DockerListImageTags(ref.Name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to list images of %s: %s", ref.Name, err))
+	}
+
+	items := selectRunImages(tags, options.MinAge, active, now)
+
+	var versions []utils.DockerImageTag
+	for _, tag := range tags {
+		if !strings.Contains(tag.Name, runImageTag) {
+			versions = append(versions, tag)
+		}
+	}
+
+	for _, name := range selectSnapshots(versions, c.GetVersioning().Strategy, ref.Tag, options, now) {
+		items = append(items, Item{Kind: "image", Name: name})
+	}
+
+	return items, nil
+}
+
+// Get run images to remove, images of active runs or created less than
+// min_age ago are kept
+func selectRunImages(tags []utils.DockerImageTag, min_age time.Duration, active map[string]bool, now time.Time) []Item {
+	var items []Item
+
+	for _, tag := range tags {
+		i := strings.Index(tag.Name, runImageTag)
+		if i < 0 {
+			continue
+		}
+
+		run_name := tag.Name[i+len("-."):]
+		if active[run_name] || now.Sub(tag.Created) < min_age {
+			continue
+		}
+		items = append(items, Item{Kind: "image", Name: tag.Name})
+	}
+
+	return items
+}
+
+// Snapshot version of image tags and when its newest image was created
+type snapshot struct {
+	version string
+	created time.Time
+	names   []string
+}
+
+// Get names of snapshot images older than options.SnapshotsOlderThan or
+// not among the options.KeepSnapshots newest snapshot versions. Test and
+// builder images belong to the version they are built for. Tags not
+// parsed as versions of strategy, like tags from TagTemplates, are kept.
+func selectSnapshots(tags []utils.DockerImageTag, strategy string, current string, options Options, now time.Time) []string {
+	if options.SnapshotsOlderThan == 0 && options.KeepSnapshots == 0 {
+		return nil
+	}
+
+	snapshots := map[string]*snapshot{}
+	for _, tag := range tags {
+		version := tag.Name[strings.LastIndex(tag.Name, ":")+1:]
+		version = strings.TrimSuffix(strings.TrimSuffix(version, "-test"), "-builder")
+
+		if version == current || !isSnapshotVersion(strategy, version) {
+			continue
+		}
+
+		s, ok := snapshots[version]
+		if !ok {
+			s = &snapshot{version: version}
+			snapshots[version] = s
+		}
+		s.names = append(s.names, tag.Name)
+		if tag.Created.After(s.created) {
+			s.created = tag.Created
+		}
+	}
+
+	// Newest first
+	var list []*snapshot
+	for _, s := range snapshots {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].created.Equal(list[j].created) {
+			return list[i].version > list[j].version
+		}
+		return list[i].created.After(list[j].created)
+	})
+
+	var names []string
+	for i, s := range list {
+		beyond := options.KeepSnapshots > 0 && i >= options.KeepSnapshots
+		older := options.SnapshotsOlderThan > 0 && now.Sub(s.created) > options.SnapshotsOlderThan
+		if beyond || older {
+			sort.Strings(s.names)
+			names = append(names, s.names...)
+		}
+	}
+	return names
+}
+
+// Check if docker tag is a snapshot version of strategy
+func isSnapshotVersion(strategy string, tag string) bool {
+	version, err := semver.ParseStrategyTag(strategy, tag)
+	return err == nil && !version.IsReleaseVersion()
+}
+
+func remove(ctx context.Context, dir string, item Item) error {
+	var command string
+
+	switch item.Kind {
+	case "dir", "file":
+		return os.RemoveAll(filepath.Join(dir, item.Name))
+	case "container":
+		// Container of an interrupted run may still be running
+		command = fmt.Sprintf("docker rm -f '%s'", item.Name)
+	case "network":
+		command = fmt.Sprintf("docker network rm '%s'", item.Name)
+	case "image":
+		command = fmt.Sprintf("docker rmi '%s'", item.Name)
+	}
+
+	if exitcode, out := utils.RunCmdContext(ctx, command); exitcode != 0 {
+		return errors.New(strings.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package clean
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/utils"
+)
+
+type CleanTestSuite struct {
+	suite.Suite
+}
+
+func TestCleanTestSuite(t *testing.T) {
+	suite.Run(t, new(CleanTestSuite))
+}
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+var imageTags = []utils.DockerImageTag{
+	{Name: "acme/api:v1.0.0", Created: now.Add(-100 * time.Hour)},
+	{Name: "acme/api:v1.0.0-1-gaaaaaaa", Created: now.Add(-90 * time.Hour)},
+	{Name: "acme/api:v1.0.0-1-gaaaaaaa-test", Created: now.Add(-90 * time.Hour)},
+	{Name: "acme/api:v1.0.0-2-gbbbbbbb", Created: now.Add(-50 * time.Hour)},
+	{Name: "acme/api:v1.0.0-3-gccccccc", Created: now.Add(-10 * time.Hour)},
+	{Name: "acme/api:v1.0.0-4-gddddddd", Created: now.Add(-1 * time.Hour)},
+	{Name: "acme/api:main-latest", Created: now.Add(-200 * time.Hour)},
+}
+
+func (suite *CleanTestSuite) TestSelectSnapshotsNone() {
+	assert.Nil(suite.T(), selectSnapshots(imageTags, "describe", "v1.0.0-4-gddddddd", Options{}, now))
+}
+
+func (suite *CleanTestSuite) TestSelectSnapshotsOlderThan() {
+	names := selectSnapshots(imageTags, "describe", "v1.0.0-4-gddddddd", Options{SnapshotsOlderThan: 24 * time.Hour}, now)
+
+	assert.Equal(suite.T(), []string{
+		"acme/api:v1.0.0-2-gbbbbbbb",
+		"acme/api:v1.0.0-1-gaaaaaaa",
+		"acme/api:v1.0.0-1-gaaaaaaa-test",
+	}, names)
+}
+
+func (suite *CleanTestSuite) TestSelectSnapshotsKeep() {
+	names := selectSnapshots(imageTags, "describe", "v1.0.0-4-gddddddd", Options{KeepSnapshots: 2}, now)
+
+	assert.Equal(suite.T(), []string{
+		"acme/api:v1.0.0-1-gaaaaaaa",
+		"acme/api:v1.0.0-1-gaaaaaaa-test",
+	}, names)
+}
+
+func (suite *CleanTestSuite) TestSelectSnapshotsKeepCurrent() {
+	names := selectSnapshots(imageTags, "describe", "v1.0.0-1-gaaaaaaa", Options{KeepSnapshots: 1}, now)
+
+	assert.Equal(suite.T(), []string{
+		"acme/api:v1.0.0-3-gccccccc",
+		"acme/api:v1.0.0-2-gbbbbbbb",
+	}, names)
+}
+
+func (suite *CleanTestSuite) TestIsSnapshotVersion() {
+	assert.True(suite.T(), isSnapshotVersion("describe", "v1.0.0-1-gaaaaaaa"))
+	assert.False(suite.T(), isSnapshotVersion("describe", "v1.0.0"))
+	assert.False(suite.T(), isSnapshotVersion("describe", "aaaaaaa"))
+	assert.True(suite.T(), isSnapshotVersion("pep440", "1.0.1.dev1-gaaaaaaa"))
+	assert.False(suite.T(), isSnapshotVersion("pep440", "1.0.1"))
+	assert.True(suite.T(), isSnapshotVersion("maven", "1.0.1-1-gaaaaaaa-SNAPSHOT"))
+}
+
+func (suite *CleanTestSuite) TestGetTempItems() {
+	dir := suite.T().TempDir()
+	os.Mkdir(filepath.Join(dir, ".wrench_build_123"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".wrench_run_456-env"), []byte("FOO=bar\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+
+	items, active, err := getTempItems(context.Background(), dir, time.Hour, time.Now().Add(2*time.Hour))
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []Item{
+			{Kind: "file", Name: ".wrench_run_456-env"},
+			{Kind: "dir", Name: ".wrench_build_123"},
+		}, items)
+		assert.Empty(suite.T(), active)
+	}
+}
+
+func (suite *CleanTestSuite) TestGetTempItemsMinAge() {
+	dir := suite.T().TempDir()
+	os.Mkdir(filepath.Join(dir, ".wrench_build_123"), 0755)
+	os.Mkdir(filepath.Join(dir, ".wrench_run_456"), 0755)
+
+	// Temp dirs of wrench running in the project are kept
+	items, active, err := getTempItems(context.Background(), dir, time.Hour, time.Now())
+
+	if assert.Nil(suite.T(), err) {
+		assert.Empty(suite.T(), items)
+		assert.Equal(suite.T(), map[string]bool{"wrench_run_456": true}, active)
+	}
+}
+
+func (suite *CleanTestSuite) TestSelectRunImages() {
+	tags := []utils.DockerImageTag{
+		{Name: "acme/api:v1.0.0-test-.wrench_run_123", Created: now.Add(-2 * time.Hour)},
+		{Name: "acme/api:v1.0.0-.wrench_run_456", Created: now.Add(-2 * time.Hour)},
+		{Name: "acme/api:v1.0.0-.wrench_run_789", Created: now.Add(-10 * time.Minute)},
+		{Name: "acme/api:v1.0.0", Created: now.Add(-2 * time.Hour)},
+	}
+
+	items := selectRunImages(tags, time.Hour, map[string]bool{"wrench_run_456": true}, now)
+
+	assert.Equal(suite.T(), []Item{{Kind: "image", Name: "acme/api:v1.0.0-test-.wrench_run_123"}}, items)
+}
+
+func (suite *CleanTestSuite) TestIsRunContainer() {
+	assert.True(suite.T(), isRunContainer("wrench_run_123", "wrench_run_123"))
+	assert.True(suite.T(), isRunContainer("wrench_run_123-postgres", "wrench_run_123"))
+	assert.False(suite.T(), isRunContainer("wrench_run_1234", "wrench_run_123"))
+	assert.False(suite.T(), isRunContainer("other_wrench_run_123", "wrench_run_123"))
+}
+
+func (suite *CleanTestSuite) TestRemoveDir() {
+	dir := suite.T().TempDir()
+	os.MkdirAll(filepath.Join(dir, ".wrench_build_123", "sub"), 0755)
+
+	assert.Nil(suite.T(), remove(context.Background(), dir, Item{Kind: "dir", Name: ".wrench_build_123"}))
+	assert.False(suite.T(), utils.FileExists(filepath.Join(dir, ".wrench_build_123")))
+}
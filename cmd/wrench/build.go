@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/report"
+)
+
+func addBuildToWrench(rootCmd *cobra.Command) {
+	var options wrench.BuildOptions
+	var flag_report string
+
+	var cmdBuild = &cobra.Command{
+		Use:   "build",
+		Short: "Build docker image",
+		Long:  `will build docker image for project`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if flag_report != "" {
+				options.Report = report.New("build")
+			}
+
+			err := project.Build(cmd.Context(), options)
+			writeReport(options.Report, flag_report, "json")
+			exitOnError(err)
+		},
+	}
+
+	cmdBuild.Flags().BoolVarP(&options.Rebuild, "rebuild", "r", false, "Force rebuild of image")
+	cmdBuild.Flags().StringVar(&flag_report, "report", "", "Write JSON report of images built to file")
+	cmdBuild.Flags().StringVar(&options.ChangedSince, "changed-since", "", "Only build if files changed since git ref, in a monorepo only changed projects")
+	cmdBuild.Flags().StringArrayVar(&options.CacheFrom, "cache-from", nil, "Use image or BuildKit cache spec as build cache, like type=local,src=.cache/docker")
+	cmdBuild.Flags().StringArrayVar(&options.CacheTo, "cache-to", nil, "Export build cache to BuildKit cache spec, like type=registry,ref=acme/api:buildcache")
+	cmdBuild.Flags().StringArrayVar(&options.Ssh, "ssh", nil, "Forward SSH agent socket or keys to build, like default")
+	cmdBuild.Flags().StringArrayVar(&options.Secrets, "secret", nil, "Pass BuildKit secret to build, like id=npmrc,src=.npmrc")
+	rootCmd.AddCommand(cmdBuild)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+)
+
+func addBumpToWrench(rootCmd *cobra.Command) {
+	var cmdBump = &cobra.Command{
+		Use:   "bump [major,minor,patch]",
+		Short: "Bump project version",
+		Long:  `will bump project version, tag git tree and tag snapshot docker image`,
+		Run: func(cmd *cobra.Command, args []string) {
+			level := "minor"
+			if len(args) == 1 {
+				level = args[0]
+			} else if len(args) > 1 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			release, err := project.Bump(cmd.Context(), level)
+			if errors.Is(err, wrench.ErrAlreadyReleased) {
+				fmt.Printf("Revision already release '%s'. Doing nothing.\n", release)
+				return
+			}
+			exitOnError(err)
+
+			fmt.Printf("Released %s\n", release)
+		},
+	}
+
+	rootCmd.AddCommand(cmdBump)
+}
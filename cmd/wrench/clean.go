@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/clean"
+	"github.com/tomologic/wrench/log"
+)
+
+func addCleanToWrench(rootCmd *cobra.Command) {
+	var options wrench.CleanOptions
+
+	var cmdClean = &cobra.Command{
+		Use:   "clean",
+		Short: "Remove leftovers of interrupted runs and old snapshot images",
+		Long:  `will remove temp dirs, containers, networks and images left by interrupted runs and builds, and optionally old snapshot images`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 0 || options.KeepSnapshots < 0 || options.SnapshotsOlderThan < 0 || options.MinAge < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			items, err := project.Clean(cmd.Context(), options)
+
+			for _, item := range items {
+				if options.DryRun {
+					fmt.Printf("Would remove %s\n", item)
+				} else {
+					fmt.Printf("Removed %s\n", item)
+				}
+			}
+			exitOnError(err)
+
+			if len(items) == 0 {
+				log.Infof("Nothing to clean")
+			}
+		},
+	}
+
+	cmdClean.Flags().BoolVarP(&options.DryRun, "dry-run", "n", false, "Only list what would be removed")
+	cmdClean.Flags().DurationVar(&options.MinAge, "min-age", clean.DefaultMinAge, "Keep temp dirs and run images created more recently, they may be in use")
+	cmdClean.Flags().DurationVar(&options.SnapshotsOlderThan, "snapshots-older-than", 0, "Also remove snapshot images created longer ago, like 720h")
+	cmdClean.Flags().IntVar(&options.KeepSnapshots, "keep-snapshots", 0, "Also remove snapshot images of all but the newest number of snapshot versions")
+
+	rootCmd.AddCommand(cmdClean)
+}
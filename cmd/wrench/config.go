@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+)
+
+func addConfigToWrench(cmdRoot *cobra.Command) {
+	var flag_format string
+	var flag_output string
+	var flag_show_origin bool
+	var flag_config string
+	var flag_directory string
+
+	var cmdConfig = &cobra.Command{
+		Use:   "config",
+		Short: "Configuration for wrench",
+		Long:  `configuration picked up by wrench and used in commands`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if flag_show_origin {
+				fmt.Println(project.Config().ShowOrigin())
+				return
+			}
+
+			if flag_output == "" {
+				out, err := project.Format(flag_format)
+				exitOnError(err)
+				fmt.Println(out)
+				return
+			}
+
+			if flag_format != "" {
+				log.Errorf("--format and --output can't be combined")
+				os.Exit(1)
+			}
+
+			out, err := project.Config().OutputConfig(flag_output)
+			exitOnError(err)
+
+			if flag_output == "github" {
+				exitOnError(config.WriteGithubOutput(out))
+			} else {
+				fmt.Println(out)
+			}
+		},
+	}
+
+	cmdConfig.Flags().StringVar(&flag_format, "format", "", "Return specific value from config")
+	cmdConfig.Flags().StringVarP(&flag_output, "output", "o", "", fmt.Sprintf("Output format, one of %s", strings.Join(config.OutputFormats, ", ")))
+	cmdConfig.Flags().BoolVar(&flag_show_origin, "show-origin", false, "Show file or detection rule each value comes from")
+
+	var cmdValidate = &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Validate wrench.yml",
+		Long:  `validate wrench.yml against the wrench.yml JSON Schema, all problems are reported with line and column`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 1 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			file := ""
+			if len(args) == 1 {
+				file = args[0]
+			} else {
+				var err error
+				file, err = config.Discover(flag_directory, config.Options{ConfigFile: flag_config})
+				exitOnError(err)
+			}
+
+			problems, err := config.ValidateFile(file, config.Options{})
+			exitOnError(err)
+
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			if len(problems) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var cmdSchema = &cobra.Command{
+		Use:   "schema",
+		Short: "Print JSON Schema for wrench.yml",
+		Long:  `print JSON Schema for wrench.yml, useful for editor integration`,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print(string(config.Schema))
+		},
+	}
+
+	var flag_changed_since string
+
+	var cmdProjects = &cobra.Command{
+		Use:   "projects",
+		Short: "List projects of monorepo",
+		Long:  `list paths of projects in monorepo in build order, dependencies first`,
+		Run: func(cmd *cobra.Command, args []string) {
+			projects, err := project.Projects(flag_changed_since)
+			exitOnError(err)
+
+			for _, project := range projects {
+				fmt.Println(project.Path)
+			}
+		},
+	}
+
+	cmdProjects.Flags().StringVar(&flag_changed_since, "changed-since", "", "Only list projects with files changed since git ref")
+
+	cmdConfig.AddCommand(cmdValidate)
+	cmdConfig.AddCommand(cmdProjects)
+	cmdConfig.AddCommand(cmdSchema)
+	cmdRoot.AddCommand(cmdConfig)
+
+	cmdRoot.PersistentFlags().StringVar(&flag_config, "config", "", "Path to config file instead of discovered wrench.yml")
+	cmdRoot.PersistentFlags().StringVarP(&flag_directory, "directory", "C", "", "Run as if wrench was started in directory")
+
+	// Load project after flags are parsed, validate and schema don't need
+	// a valid config. Shell completion works outside of projects.
+	cmdRoot.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if cmd.Name() == "version" || cmd.Name() == "help" {
+			return
+		}
+
+		if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd ||
+			(cmd.HasParent() && cmd.Parent().Name() == "completion") {
+			return
+		}
+
+		if cmd == cmdValidate || cmd == cmdSchema {
+			return
+		}
+
+		var err error
+		project, err = wrench.Load(flag_directory, wrench.Options{ConfigFile: flag_config})
+		exitOnError(err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/report"
+	"github.com/tomologic/wrench/secrets"
+)
+
+// VERSION to return in "wrench version" command
+var VERSION = "0.0.0"
+
+// Project loaded from --directory and --config before commands are run
+var project *wrench.Project
+
+// Context of commands, cancelled when wrench is interrupted
+var root_context context.Context
+
+func main() {
+	var rootCmd = &cobra.Command{Use: "wrench"}
+
+	config.WrenchVersion = strings.Trim(VERSION, "'")
+
+	addBuildToWrench(rootCmd)
+	addBumpToWrench(rootCmd)
+	addCleanToWrench(rootCmd)
+	addPushToWrench(rootCmd)
+	addConfigToWrench(rootCmd)
+	addRunToWrench(rootCmd)
+	addShellToWrench(rootCmd)
+
+	var cmdVersion = &cobra.Command{
+		Use:   "version",
+		Short: "Version of wrench",
+		Long:  `Version of wrench`,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(strings.Trim(VERSION, "'"))
+		},
+	}
+	rootCmd.AddCommand(cmdVersion)
+
+	addLoggingToWrench(rootCmd)
+
+	root_context = signalContext()
+	rootCmd.ExecuteContext(root_context)
+}
+
+// Add flags for level and format of messages written to stderr, applied
+// after flags are parsed and before commands are run
+func addLoggingToWrench(cmdRoot *cobra.Command) {
+	var flag_verbose bool
+	var flag_quiet bool
+	var flag_log_format string
+
+	cmdRoot.PersistentFlags().BoolVarP(&flag_verbose, "verbose", "v", false, "Also print debug messages, like docker commands run")
+	cmdRoot.PersistentFlags().BoolVarP(&flag_quiet, "quiet", "q", false, "Only print error messages")
+	cmdRoot.PersistentFlags().StringVar(&flag_log_format, "log-format", "text", fmt.Sprintf("Format of messages, one of %s", strings.Join(log.Formats, ", ")))
+
+	cobra.OnInitialize(func() {
+		if flag_verbose && flag_quiet {
+			exitOnError(errors.New("--verbose and --quiet can't be combined"))
+		}
+
+		exitOnError(log.SetFormat(flag_log_format))
+
+		if flag_verbose {
+			log.SetLevel(log.LevelDebug)
+		}
+		if flag_quiet {
+			log.SetLevel(log.LevelError)
+		}
+	})
+}
+
+// Exit with exit code of failed command, or print error and exit 1
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+
+	// Commands were stopped, exit like an interrupted process
+	if root_context != nil && root_context.Err() != nil {
+		log.Errorf("Interrupted")
+		os.Exit(130)
+	}
+
+	// Command in container failed, it has already reported why
+	var run_error *wrench.RunError
+	if errors.As(err, &run_error) {
+		os.Exit(run_error.ExitCode)
+	}
+
+	log.Errorf("%s", secrets.Mask(err.Error()))
+	os.Exit(1)
+}
+
+// Write report to file if requested, also when the command failed
+func writeReport(r *report.Report, file string, format string) {
+	if file == "" {
+		return
+	}
+	exitOnError(r.WriteFile(file, format))
+}
+
+// Get context cancelled when wrench receives SIGINT or SIGTERM. Commands
+// are stopped and clean up before wrench exits, a second signal exits
+// immediately.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		log.Warningf("Interrupted, stopping and cleaning up. Interrupt again to exit immediately.")
+		cancel()
+
+		<-signals
+		os.Exit(130)
+	}()
+
+	return ctx
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/report"
+)
+
+func addPushToWrench(rootCmd *cobra.Command) {
+	var flag_additional_tags string
+	var flag_report string
+
+	var cmdPush = &cobra.Command{
+		Use:   "push [--additional-tags]",
+		Short: "Push project release image to docker registry",
+		Long:  `will push release image for current version to specified registry`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			options := wrench.PushOptions{AdditionalTags: strings.Split(flag_additional_tags, ",")}
+			if flag_report != "" {
+				options.Report = report.New("push")
+			}
+
+			err := project.Push(cmd.Context(), args[0], options)
+			writeReport(options.Report, flag_report, "json")
+			exitOnError(err)
+		},
+	}
+
+	cmdPush.Flags().StringVar(&flag_additional_tags, "additional-tags", "", "Comma separated list of additional tags to push 'latest,prod'")
+	cmdPush.Flags().StringVar(&flag_report, "report", "", "Write JSON report of tags pushed with digests to file")
+
+	rootCmd.AddCommand(cmdPush)
+}
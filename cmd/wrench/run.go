@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench"
+	"github.com/tomologic/wrench/report"
+	"github.com/tomologic/wrench/run"
+)
+
+func addRunToWrench(cmdRoot *cobra.Command) {
+	var flag_jobs int
+	var flag_keep_going bool
+	var flag_params []string
+	var flag_report string
+	var flag_report_format string
+
+	var cmdRun = &cobra.Command{
+		Use:   "run [command] [-- args...]",
+		Short: "Run commands in docker image",
+		Long:  `Run defined commands from wrench.yml inside application image, after the commands they depend on`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Arguments after -- are passed to the run command
+			var extra_args []string
+			if dash := cmd.ArgsLenAtDash(); dash != -1 {
+				extra_args = args[dash:]
+				args = args[:dash]
+			}
+
+			if len(args) != 1 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			params, err := run.ParseParams(flag_params)
+			exitOnError(err)
+
+			exitOnError(report.CheckFormat(flag_report_format))
+
+			options := wrench.RunOptions{
+				Args:      extra_args,
+				Params:    params,
+				Jobs:      flag_jobs,
+				KeepGoing: flag_keep_going,
+			}
+			if flag_report != "" {
+				options.Report = report.New(fmt.Sprintf("run %s", args[0]))
+			}
+
+			err = project.Run(cmd.Context(), args[0], options)
+			writeReport(options.Report, flag_report, flag_report_format)
+			exitOnError(err)
+		},
+	}
+
+	cmdRun.Flags().IntVarP(&flag_jobs, "jobs", "j", 1, "Number of run commands to run in parallel")
+	cmdRun.Flags().BoolVarP(&flag_keep_going, "keep-going", "k", false, "Keep running commands not depending on a failed command")
+	cmdRun.Flags().StringArrayVarP(&flag_params, "param", "p", nil, "Set named param of run command 'name=value'")
+	cmdRun.Flags().StringVar(&flag_report, "report", "", "Write report of exit code and duration of every command run to file")
+	cmdRun.Flags().StringVar(&flag_report_format, "report-format", "json", fmt.Sprintf("Format of report, one of %s", strings.Join(report.Formats, ", ")))
+
+	cmdRoot.AddCommand(cmdRun)
+}
+
+func addShellToWrench(cmdRoot *cobra.Command) {
+	var options wrench.ShellOptions
+
+	var cmdShell = &cobra.Command{
+		Use:   "shell [--image test|builder|final]",
+		Short: "Open interactive shell in docker image",
+		Long:  `Open interactive shell inside project image with env, mounts and services of a run command`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			exitOnError(project.Shell(cmd.Context(), options))
+		},
+	}
+
+	var cmdExec = &cobra.Command{
+		Use:   "exec [--image test|builder|final] -- command [args...]",
+		Short: "Execute command in docker image",
+		Long:  `Execute arbitrary command inside project image with env, mounts and services of a run command`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			options.Command = args
+			exitOnError(project.Shell(cmd.Context(), options))
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{cmdShell, cmdExec} {
+		cmd.Flags().StringVar(&options.Image, "image", "", "Project image to use, test, builder or final")
+		cmd.Flags().StringVar(&options.Run, "run", "", "Use env, mounts and services of run command")
+		cmdRoot.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+)
+
+// Backend building docker images
+type Build struct {
+	// docker or buildx, buildx is used regardless when Builder, Endpoint or
+	// the build cache needs it
+	Backend string `yaml:"Backend,omitempty" json:"Backend,omitempty"`
+
+	// Name of buildx builder, the current builder if not set
+	Builder string `yaml:"Builder,omitempty" json:"Builder,omitempty"`
+
+	// Remote BuildKit daemon, like tcp://buildkitd:1234. A buildx builder
+	// with the remote driver is created for it if missing.
+	Endpoint string `yaml:"Endpoint,omitempty" json:"Endpoint,omitempty"`
+
+	// SSH agent sockets or keys forwarded to builds, like default
+	Ssh []string `yaml:"Ssh,omitempty" json:"Ssh,omitempty"`
+}
+
+// Backends building docker images
+var BuildBackends = []string{"docker", "buildx"}
+
+func validateBuild(build *Build) error {
+	if build == nil {
+		return nil
+	}
+
+	if build.Backend != "" && !utils.StringInSlice(build.Backend, BuildBackends) {
+		return errors.New(fmt.Sprintf("Build Backend must be one of %s", strings.Join(BuildBackends, ", ")))
+	}
+
+	if build.Endpoint != "" && !strings.Contains(build.Endpoint, "://") {
+		return errors.New(fmt.Sprintf("Build Endpoint '%s' must be an address like tcp://buildkitd:1234", build.Endpoint))
+	}
+
+	for _, ssh := range build.Ssh {
+		if ssh == "" || strings.HasPrefix(ssh, "=") {
+			return errors.New(fmt.Sprintf("Build Ssh '%s' must be default or an id with optional sockets or keys, like github=~/.ssh/id_ed25519", ssh))
+		}
+	}
+
+	return nil
+}
+
+// Get build backend of project with defaults for values not set
+func (r *Resolved) GetBuild() Build {
+	build := Build{}
+	if r.config.Build != nil {
+		build = *r.config.Build
+	}
+	if build.Backend == "" {
+		build.Backend = "docker"
+	}
+	return build
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BuildTestSuite struct {
+	suite.Suite
+}
+
+func TestBuildTestSuite(t *testing.T) {
+	suite.Run(t, new(BuildTestSuite))
+}
+
+func (suite *BuildTestSuite) TestGetBuildDefaults() {
+	assert.Equal(suite.T(), Build{Backend: "docker"}, newTestResolved("/src/api", Config{}, nil).GetBuild())
+
+	r := newTestResolved("/src/api", Config{Build: &Build{Endpoint: "tcp://buildkitd:1234", Ssh: []string{"default"}}}, nil)
+	assert.Equal(suite.T(), Build{Backend: "docker", Endpoint: "tcp://buildkitd:1234", Ssh: []string{"default"}}, r.GetBuild())
+}
+
+func (suite *BuildTestSuite) TestValidateBuild() {
+	assert.Nil(suite.T(), validateBuild(nil))
+	assert.Nil(suite.T(), validateBuild(&Build{Backend: "buildx", Builder: "ci", Endpoint: "tcp://buildkitd:1234", Ssh: []string{"default", "github=/keys/github"}}))
+
+	for build, message := range map[*Build]string{
+		{Backend: "kaniko"}:          "Build Backend must be one of docker, buildx",
+		{Endpoint: "buildkitd:1234"}: "Build Endpoint 'buildkitd:1234' must be an address like tcp://buildkitd:1234",
+		{Ssh: []string{""}}:          "Build Ssh '' must be default or an id with optional sockets or keys, like github=~/.ssh/id_ed25519",
+	} {
+		err := validateBuild(build)
+		if assert.NotNil(suite.T(), err) {
+			assert.Equal(suite.T(), message, err.Error())
+		}
+	}
+}
+
+func (suite *BuildTestSuite) TestMergeConfigBuild() {
+	base := Config{Build: &Build{Backend: "buildx", Builder: "ci", Ssh: []string{"default"}}}
+	override := Config{Build: &Build{Endpoint: "tcp://buildkitd:1234", Ssh: []string{"github"}}}
+
+	merged := mergeConfig(base, override)
+
+	assert.Equal(suite.T(), &Build{Backend: "buildx", Builder: "ci", Endpoint: "tcp://buildkitd:1234", Ssh: []string{"github"}}, merged.Build)
+	assert.Equal(suite.T(), base.Build, mergeConfig(base, Config{}).Build)
+}
+
+func (suite *BuildTestSuite) TestParseConfigBuild() {
+	config, err := parseConfig("Build:\n  Backend: buildx\n  Endpoint: tcp://buildkitd:1234\n  Ssh:\n    - default\n")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), &Build{Backend: "buildx", Endpoint: "tcp://buildkitd:1234", Ssh: []string{"default"}}, config.Build)
+	}
+}
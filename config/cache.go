@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Cache of docker builds
+type Cache struct {
+	// Cache sources, image references or BuildKit cache specs like
+	// type=local,src=.cache/docker
+	From []string `yaml:"From,omitempty" json:"From,omitempty"`
+
+	// Cache destinations, BuildKit cache specs like
+	// type=registry,ref=acme/api:buildcache,mode=max
+	To []string `yaml:"To,omitempty" json:"To,omitempty"`
+
+	// Use previous snapshot or release image of project as cache source,
+	// nil for true
+	Previous *bool `yaml:"Previous,omitempty" json:"Previous,omitempty"`
+}
+
+// Types of BuildKit cache specs
+var CacheTypes = []string{"registry", "local", "inline", "gha", "s3", "azblob"}
+
+// BuildKit cache source or destination
+type CacheSpec struct {
+	Type  string
+	Attrs map[string]string
+}
+
+// Parse cache source, or destination if to is set. An image reference is
+// a registry cache.
+func ParseCacheSpec(spec string, to bool) (CacheSpec, error) {
+	if !strings.Contains(spec, "=") {
+		if _, err := image.Parse(spec); err != nil {
+			return CacheSpec{}, errors.New(fmt.Sprintf("Invalid cache '%s': %s", spec, err))
+		}
+		return CacheSpec{Type: "registry", Attrs: map[string]string{"ref": spec}}, nil
+	}
+
+	cache := CacheSpec{Attrs: map[string]string{}}
+	for _, part := range strings.Split(spec, ",") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return CacheSpec{}, errors.New(fmt.Sprintf("Invalid cache '%s', expected key=value pairs", spec))
+		}
+		if pair[0] == "type" {
+			cache.Type = pair[1]
+		} else {
+			cache.Attrs[pair[0]] = pair[1]
+		}
+	}
+
+	if !utils.StringInSlice(cache.Type, CacheTypes) {
+		return CacheSpec{}, errors.New(fmt.Sprintf("Invalid cache '%s', type must be one of %s", spec, strings.Join(CacheTypes, ", ")))
+	}
+
+	required := ""
+	switch {
+	case cache.Type == "registry":
+		required = "ref"
+	case cache.Type == "local" && to:
+		required = "dest"
+	case cache.Type == "local":
+		required = "src"
+	case cache.Type == "inline" && !to:
+		return CacheSpec{}, errors.New(fmt.Sprintf("Invalid cache '%s', inline is only a cache destination, use the image as cache source", spec))
+	}
+	if required != "" && cache.Attrs[required] == "" {
+		return CacheSpec{}, errors.New(fmt.Sprintf("Invalid cache '%s', %s cache needs %s", spec, cache.Type, required))
+	}
+
+	return cache, nil
+}
+
+// Format as BuildKit cache spec, attributes sorted by name
+func (s CacheSpec) String() string {
+	parts := []string{"type=" + s.Type}
+
+	var keys []string
+	for key := range s.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, s.Attrs[key]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Copy of cache for image with tag suffix, like -test or -builder, so
+// project images don't share a cache. Registry refs get the suffix in
+// their tag and local dirs in their name.
+func (s CacheSpec) WithSuffix(suffix string) CacheSpec {
+	cache := CacheSpec{Type: s.Type, Attrs: map[string]string{}}
+	for key, value := range s.Attrs {
+		cache.Attrs[key] = value
+	}
+
+	if suffix == "" {
+		return cache
+	}
+
+	switch s.Type {
+	case "registry":
+		if ref, err := image.Parse(s.Attrs["ref"]); err == nil {
+			if ref.Tag == "" {
+				ref.Tag = "latest"
+			}
+			cache.Attrs["ref"] = ref.WithTagSuffix(suffix).String()
+		}
+	case "local":
+		for _, key := range []string{"src", "dest"} {
+			if value, ok := s.Attrs[key]; ok {
+				cache.Attrs[key] = strings.TrimRight(value, "/") + suffix
+			}
+		}
+	}
+
+	return cache
+}
+
+func validateCache(cache *Cache) error {
+	if cache == nil {
+		return nil
+	}
+
+	for _, spec := range cache.From {
+		if _, err := ParseCacheSpec(spec, false); err != nil {
+			return errors.New(fmt.Sprintf("Cache From: %s", err))
+		}
+	}
+	for _, spec := range cache.To {
+		if _, err := ParseCacheSpec(spec, true); err != nil {
+			return errors.New(fmt.Sprintf("Cache To: %s", err))
+		}
+	}
+
+	return nil
+}
+
+// Get cache config of project, empty if not set
+func (r *Resolved) GetCache() Cache {
+	if r.config.Cache == nil {
+		return Cache{}
+	}
+	return *r.config.Cache
+}
+
+// Check if previous snapshot or release image is used as cache source
+func (r *Resolved) UsePreviousImageCache() bool {
+	previous := r.GetCache().Previous
+	return previous == nil || *previous
+}
+
+// Parse cache sources, or destinations if to is set, of config and
+// additional specs. Local cache dirs are relative to project directory.
+func (r *Resolved) GetCacheSpecs(to bool, additional []string) ([]CacheSpec, error) {
+	specs := r.GetCache().From
+	if to {
+		specs = r.GetCache().To
+	}
+	specs = append(append([]string{}, specs...), additional...)
+
+	var caches []CacheSpec
+	for _, spec := range specs {
+		cache, err := ParseCacheSpec(spec, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if cache.Type == "local" {
+			for _, key := range []string{"src", "dest"} {
+				if value, ok := cache.Attrs[key]; ok && !filepath.IsAbs(value) {
+					cache.Attrs[key] = filepath.Join(r.dir, value)
+				}
+			}
+		}
+
+		caches = append(caches, cache)
+	}
+
+	return caches, nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (suite *CacheTestSuite) TestParseCacheSpecImage() {
+	spec, err := ParseCacheSpec("registry.example.com/acme/api:cache", false)
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "type=registry,ref=registry.example.com/acme/api:cache", spec.String())
+	}
+}
+
+func (suite *CacheTestSuite) TestParseCacheSpec() {
+	spec, err := ParseCacheSpec("type=registry,ref=acme/api:cache,mode=max", true)
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "registry", spec.Type)
+		assert.Equal(suite.T(), map[string]string{"ref": "acme/api:cache", "mode": "max"}, spec.Attrs)
+		assert.Equal(suite.T(), "type=registry,mode=max,ref=acme/api:cache", spec.String())
+	}
+}
+
+func (suite *CacheTestSuite) TestParseCacheSpecErrors() {
+	for _, test := range []struct {
+		spec string
+		to   bool
+		err  string
+	}{
+		{"type=foo,ref=acme/api", false, "Invalid cache 'type=foo,ref=acme/api', type must be one of registry, local, inline, gha, s3, azblob"},
+		{"type=registry", false, "Invalid cache 'type=registry', registry cache needs ref"},
+		{"type=local,dest=.cache", false, "Invalid cache 'type=local,dest=.cache', local cache needs src"},
+		{"type=local,src=.cache", true, "Invalid cache 'type=local,src=.cache', local cache needs dest"},
+		{"type=inline", false, "Invalid cache 'type=inline', inline is only a cache destination, use the image as cache source"},
+		{"type=local,src", false, "Invalid cache 'type=local,src', expected key=value pairs"},
+	} {
+		_, err := ParseCacheSpec(test.spec, test.to)
+		if assert.NotNil(suite.T(), err, test.spec) {
+			assert.Equal(suite.T(), test.err, err.Error())
+		}
+	}
+
+	_, err := ParseCacheSpec("type=inline", true)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *CacheTestSuite) TestWithSuffix() {
+	registry, _ := ParseCacheSpec("acme/api", false)
+	tagged, _ := ParseCacheSpec("type=registry,ref=acme/api:cache,mode=max", true)
+	local, _ := ParseCacheSpec("type=local,dest=/tmp/cache/", true)
+
+	assert.Equal(suite.T(), "type=registry,ref=acme/api:latest-test", registry.WithSuffix("-test").String())
+	assert.Equal(suite.T(), "type=registry,mode=max,ref=acme/api:cache-builder", tagged.WithSuffix("-builder").String())
+	assert.Equal(suite.T(), "type=local,dest=/tmp/cache-test", local.WithSuffix("-test").String())
+	assert.Equal(suite.T(), "type=local,dest=/tmp/cache/", local.WithSuffix("").String())
+
+	// Original is not changed
+	assert.Equal(suite.T(), "type=registry,mode=max,ref=acme/api:cache", tagged.String())
+}
+
+func (suite *CacheTestSuite) TestGetCacheSpecs() {
+	r := newTestResolved("/src/api", Config{Cache: &Cache{
+		From: []string{"type=local,src=.cache/docker"},
+		To:   []string{"type=local,dest=.cache/docker", "type=inline"},
+	}}, nil)
+
+	from, err := r.GetCacheSpecs(false, []string{"acme/api:cache"})
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []CacheSpec{
+			{Type: "local", Attrs: map[string]string{"src": "/src/api/.cache/docker"}},
+			{Type: "registry", Attrs: map[string]string{"ref": "acme/api:cache"}},
+		}, from)
+	}
+
+	to, err := r.GetCacheSpecs(true, nil)
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []CacheSpec{
+			{Type: "local", Attrs: map[string]string{"dest": "/src/api/.cache/docker"}},
+			{Type: "inline", Attrs: map[string]string{}},
+		}, to)
+	}
+
+	_, err = r.GetCacheSpecs(true, []string{"type=local"})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *CacheTestSuite) TestUsePreviousImageCache() {
+	no := false
+
+	assert.True(suite.T(), newTestResolved("/src/api", Config{}, nil).UsePreviousImageCache())
+	assert.False(suite.T(), newTestResolved("/src/api", Config{Cache: &Cache{Previous: &no}}, nil).UsePreviousImageCache())
+}
+
+func (suite *CacheTestSuite) TestValidateCache() {
+	assert.Nil(suite.T(), validateCache(nil))
+	assert.Nil(suite.T(), validateCache(&Cache{From: []string{"acme/api"}, To: []string{"type=inline"}}))
+
+	err := validateCache(&Cache{To: []string{"type=registry"}})
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Cache To: Invalid cache 'type=registry', registry cache needs ref", err.Error())
+	}
+}
+
+func (suite *CacheTestSuite) TestMergeConfigCache() {
+	no := false
+	base := Config{Cache: &Cache{From: []string{"acme/api:cache"}, To: []string{"type=inline"}}}
+	override := Config{Cache: &Cache{To: []string{"type=local,dest=.cache"}, Previous: &no}}
+
+	merged := mergeConfig(base, override)
+
+	assert.Equal(suite.T(), &Cache{From: []string{"acme/api:cache"}, To: []string{"type=local,dest=.cache"}, Previous: &no}, merged.Cache)
+	assert.Equal(suite.T(), []string{"type=inline"}, base.Cache.To)
+	assert.Equal(suite.T(), base.Cache, mergeConfig(base, Config{}).Cache)
+}
+
+func (suite *CacheTestSuite) TestLoadCachePrevious() {
+	dir := suite.T().TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "wrench.yml"), []byte("Project:\n  Name: api\n  Version: 1.0\nCache:\n  Previous: false\n"), 0644)
+
+	problems, err := ValidateFile(filepath.Join(dir, "wrench.yml"), Options{Git: &fakeGit{}, Host: fakeHost{"host.acme.com"}, Env: fakeEnv{}})
+	if assert.Nil(suite.T(), err) {
+		assert.Empty(suite.T(), problems)
+	}
+
+	r, err := Load(dir, Options{Git: &fakeGit{}, Host: fakeHost{"host.acme.com"}, Env: fakeEnv{}})
+	if assert.Nil(suite.T(), err) {
+		assert.False(suite.T(), r.UsePreviousImageCache())
+	}
+}
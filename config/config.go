@@ -5,111 +5,191 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 
-	"github.com/spf13/cobra"
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/semver"
 	"github.com/tomologic/wrench/utils"
 	"gopkg.in/yaml.v2"
 )
 
 type Project struct {
-	Organization string `yaml:"Organization"`
-	Name         string `yaml:"Name"`
-	Version      string `yaml:"Version"`
-	Image        string `yaml:"Image"`
+	Organization string `yaml:"Organization" json:"Organization"`
+	Name         string `yaml:"Name" json:"Name"`
+	Version      string `yaml:"Version" json:"Version"`
+	Image        string `yaml:"Image" json:"Image"`
 }
 type Run struct {
-	Cmd string   `yaml:"Cmd"`
-	Env []string `yaml:"Env,omitempty"`
+	Cmd        string   `yaml:"Cmd" json:"Cmd"`
+	Env        []string `yaml:"Env,omitempty" json:"Env,omitempty"`
+	Image      string   `yaml:"Image,omitempty" json:"Image,omitempty"`
+	Entrypoint string   `yaml:"Entrypoint,omitempty" json:"Entrypoint,omitempty"`
+	Workdir    string   `yaml:"Workdir,omitempty" json:"Workdir,omitempty"`
+	User       string   `yaml:"User,omitempty" json:"User,omitempty"`
+	Volumes    []string `yaml:"Volumes,omitempty" json:"Volumes,omitempty"`
+
+	Services  map[string]Service `yaml:"Services,omitempty" json:"Services,omitempty"`
+	DependsOn []string           `yaml:"DependsOn,omitempty" json:"DependsOn,omitempty"`
+
+	// Named params with default values, nil if required
+	Params map[string]*string `yaml:"Params,omitempty" json:"Params,omitempty"`
+
+	Secrets []string `yaml:"Secrets,omitempty" json:"Secrets,omitempty"`
+}
+type Secret struct {
+	Env     string `yaml:"Env,omitempty" json:"Env,omitempty"`
+	File    string `yaml:"File,omitempty" json:"File,omitempty"`
+	Command string `yaml:"Command,omitempty" json:"Command,omitempty"`
+}
+type Service struct {
+	Image string `yaml:"Image" json:"Image"`
+
+	// Arguments of command, Cmd in wrench.yml is a list or a string split
+	// into words like sh does
+	Cmd       []string `yaml:"Cmd,omitempty" json:"Cmd,omitempty"`
+	Env       []string `yaml:"Env,omitempty" json:"Env,omitempty"`
+	HealthCmd string   `yaml:"HealthCmd,omitempty" json:"HealthCmd,omitempty"`
+}
+
+type Versioning struct {
+	Strategy string `yaml:"Strategy,omitempty" json:"Strategy,omitempty"`
+
+	// Branches using plain describe versions with branch-sha strategy
+	MainBranches []string `yaml:"MainBranches,omitempty" json:"MainBranches,omitempty"`
+
+	// Part of version tags before the version, nil for the default v
+	TagPrefix *string `yaml:"TagPrefix,omitempty" json:"TagPrefix,omitempty"`
+
+	// Glob matching version tags, derived from TagPrefix if not set
+	TagPattern string `yaml:"TagPattern,omitempty" json:"TagPattern,omitempty"`
 }
+
+// Main branches if not set in config
+var DefaultMainBranches = []string{"main", "master"}
+
+// Images a run command can be executed in
+var RunImages = []string{"test", "builder", "final"}
+
 type Config struct {
-	Project Project        `yaml:"Project"`
-	Run     map[string]Run `yaml:"Run,omitempty"`
+	Project    Project     `yaml:"Project" json:"Project"`
+	Versioning *Versioning `yaml:"Versioning,omitempty" json:"Versioning,omitempty"`
+
+	// Templates of tags images are tagged with
+	TagTemplates []string `yaml:"TagTemplates,omitempty" json:"TagTemplates,omitempty"`
+
+	Build *Build `yaml:"Build,omitempty" json:"Build,omitempty"`
+	Cache *Cache `yaml:"Cache,omitempty" json:"Cache,omitempty"`
+
+	Run     map[string]Run    `yaml:"Run,omitempty" json:"Run,omitempty"`
+	Secrets map[string]Secret `yaml:"Secrets,omitempty" json:"Secrets,omitempty"`
+
+	// Projects of monorepo, only in the top-level wrench.yml
+	Projects []SubProject `yaml:"Projects,omitempty" json:"Projects,omitempty"`
 }
 type TemplateContext struct {
 	Environ *map[string]string
+	Git     GitInfo
+	Project Project
+	OS      string
+	Arch    string
+	Wrench  WrenchInfo
+}
+type GitInfo struct {
+	Sha      string
+	ShortSha string
+	Branch   string
+	Tag      string
+	Dirty    bool
+}
+type WrenchInfo struct {
+	Version string
 }
 
-var config = &Config{}
+// Version of wrench, set by main
+var WrenchVersion = "0.0.0"
 
-func AddToWrench(cmdRoot *cobra.Command) {
-	var flag_format string
+// Validate file and get all problems found, schema problems with line and
+// column. File is valid if there are no problems. Templates in file are
+// rendered with dependencies of options.
+func ValidateFile(file string, options Options) ([]string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
 
-	var cmdConfig = &cobra.Command{
-		Use:   "config",
-		Short: "Configuration for wrench",
-		Long:  `configuration picked up by wrench and used in commands`,
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println(commandConfig(flag_format))
-		},
+	content, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
 	}
 
-	cmdConfig.Flags().StringVar(&flag_format, "format", "", "Return specific value from config")
+	r := newResolved(filepath.Dir(abs), options)
 
-	cmdRoot.AddCommand(cmdConfig)
-	c, err := loadConfigFile()
+	rendered, err := r.render(string(content), Project{})
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
-	config = &c
-}
 
-func commandConfig(format string) string {
-	// Detect values for fields not set
-	GetProjectOrganization()
-	GetProjectName()
-	GetProjectVersion()
-	GetProjectImage()
+	name := r.getDisplayFileName(abs)
 
-	if format == "" {
-		d, err := yaml.Marshal(&config)
-		if err != nil {
-			panic(err)
-		}
-		return string(d)
-	} else {
-		tmpl, err := template.New("format").Parse(format)
-		if err != nil {
-			fmt.Printf("ERROR: %s\n", err)
-			os.Exit(1)
-		}
-		var out bytes.Buffer
-		err = tmpl.Execute(&out, &config)
-		if err != nil {
-			fmt.Printf("ERROR: %s\n", err)
-			os.Exit(1)
+	validation_errors, err := Validate(name, rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, e := range validation_errors {
+		problems = append(problems, e.Error())
+	}
+
+	// Semantic errors not covered by schema, like dependency cycles. Global
+	// and local config only override parts of the project config and are
+	// only checked to be parsable.
+	unmarshall := unmarshallConfig
+	if r.isConfigOverlay(abs) {
+		unmarshall = parseConfig
+	}
+	if len(validation_errors) == 0 {
+		if _, err := unmarshall(rendered); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
 		}
-		return out.String()
 	}
-}
 
-var getEnviron = func() []string {
-	return os.Environ()
+	return problems, nil
 }
 
-var getTmplContextEnviron = func() *map[string]string {
-	Environ := make(map[string]string)
-
-	// Get all environment variables
-	for _, item := range getEnviron() {
-		splits := strings.Split(item, "=")
-		Environ[splits[0]] = strings.Join(splits[1:], "=")
+// Get config as yaml, or value rendered from format template
+func (r *Resolved) FormatConfig(format string) (string, error) {
+	if format == "" {
+		d, err := yaml.Marshal(&r.config)
+		if err != nil {
+			return "", err
+		}
+		return string(d), nil
 	}
 
-	return &Environ
+	tmpl := template.New("format")
+	tmpl, err := tmpl.Funcs(r.getTemplateFuncs(tmpl)).Parse(format)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, &r.config); err != nil {
+		return "", err
+	}
+	return out.String(), nil
 }
 
-var getConfigContent = func() (string, error) {
-	if !utils.FileExists("./wrench.yml") {
+var getConfigContent = func(file string) (string, error) {
+	if file == "" || !utils.FileExists(file) {
 		return "", nil
 	}
 
-	content, err := ioutil.ReadFile("./wrench.yml")
+	content, err := ioutil.ReadFile(file)
 	if err != nil {
 		return "", err
 	}
@@ -117,29 +197,22 @@ var getConfigContent = func() (string, error) {
 	return strings.TrimSpace(string(content)), nil
 }
 
-var getRenderedConfigContent = func(content string) (string, error) {
-	// Create template from wrench file
-	var config_rendered bytes.Buffer
-	tmpl, err := template.New("config").Parse(content)
+var unmarshallConfigRun = func(item yaml.MapItem) (string, Run, error) {
+	name, run, err := parseConfigRun(item)
 	if err != nil {
-		return "", err
-	}
-
-	// Get context for template
-	tmpl_context := TemplateContext{
-		Environ: getTmplContextEnviron(),
+		return name, run, err
 	}
 
-	// Render template with tmpl_context
-	err = tmpl.Execute(&config_rendered, tmpl_context)
-	if err != nil {
-		return "", err
+	if err := validateRun(name, run); err != nil {
+		return name, run, err
 	}
 
-	return config_rendered.String(), nil
+	return name, run, nil
 }
 
-var unmarshallConfigRun = func(item yaml.MapItem) (string, Run, error) {
+// Parse run item without validating it, layered config files may only
+// override some keys of a run item
+func parseConfigRun(item yaml.MapItem) (string, Run, error) {
 	run := Run{}
 
 	name, ok := item.Key.(string)
@@ -164,40 +237,244 @@ var unmarshallConfigRun = func(item yaml.MapItem) (string, Run, error) {
 		}
 
 		for k := range run_expanded {
-			if run_expanded[k].Key.(string) == "Cmd" {
-				cmd_string, ok = run_expanded[k].Value.(string)
+			key, ok := run_expanded[k].Key.(string)
+			if !ok {
+				return name, run, errors.New(fmt.Sprintf("Unable to parse key as string for run item %s", name))
+			}
+
+			value := run_expanded[k].Value
+
+			var err error
+			switch key {
+			case "Cmd":
+				cmd_string, ok = value.(string)
 				if !ok {
 					return name, run, errors.New(fmt.Sprintf("Unable to parse Cmd item as string for run item %s", name))
 				}
 				run.Cmd = strings.TrimSpace(cmd_string)
-			} else if run_expanded[k].Key.(string) == "Env" {
-				env_list, ok := run_expanded[k].Value.([]interface{})
+			case "Env":
+				run.Env, err = unmarshallStringList(value, key, name)
+			case "Volumes":
+				run.Volumes, err = unmarshallStringList(value, key, name)
+			case "Image":
+				run.Image, err = unmarshallString(value, key, name)
+			case "Entrypoint":
+				run.Entrypoint, err = unmarshallString(value, key, name)
+			case "Workdir":
+				run.Workdir, err = unmarshallString(value, key, name)
+			case "User":
+				run.User, err = unmarshallString(value, key, name)
+			case "Services":
+				run.Services, err = unmarshallServices(value, name)
+			case "DependsOn":
+				run.DependsOn, err = unmarshallStringList(value, key, name)
+			case "Params":
+				run.Params, err = unmarshallParams(value, name)
+			case "Secrets":
+				run.Secrets, err = unmarshallStringList(value, key, name)
+			}
+			if err != nil {
+				return name, run, err
+			}
+		}
+	}
+
+	return name, run, nil
+}
+
+func unmarshallString(value interface{}, key string, name string) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Unable to parse %s item as string for run item %s", key, name))
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func unmarshallStringList(value interface{}, key string, name string) ([]string, error) {
+	var list []string
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return list, errors.New(fmt.Sprintf("Unable to parse %s as list for run item %s", key, name))
+	}
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return list, errors.New(fmt.Sprintf("Unable to parse %s item as string for run item %s", key, name))
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Get service command from list of arguments or from string split into
+// words like sh does
+func unmarshallServiceCmd(value interface{}, key string, name string) ([]string, error) {
+	if _, ok := value.([]interface{}); ok {
+		return unmarshallStringList(value, key, name)
+	}
+
+	cmd, err := unmarshallString(value, key, name)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := utils.SplitShellWords(cmd)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to parse %s for run item %s: %s", key, name, err))
+	}
+	return args, nil
+}
+
+var serviceNameRegexp = regexp.MustCompile("^[a-z0-9][a-z0-9_.-]*$")
+
+func unmarshallServices(value interface{}, name string) (map[string]Service, error) {
+	services := make(map[string]Service)
+
+	items, ok := value.(yaml.MapSlice)
+	if !ok {
+		return services, errors.New(fmt.Sprintf("Unable to parse Services as map for run item %s", name))
+	}
+
+	for _, item := range items {
+		service_name, ok := item.Key.(string)
+		if !ok || !serviceNameRegexp.MatchString(service_name) {
+			return services, errors.New(fmt.Sprintf("Invalid service name '%v' for run item %s", item.Key, name))
+		}
+
+		service := Service{}
+
+		// Simple structure
+		// postgres: postgres:15
+		if image, ok := item.Value.(string); ok {
+			service.Image = strings.TrimSpace(image)
+		} else {
+			service_expanded, ok := item.Value.(yaml.MapSlice)
+			if !ok {
+				return services, errors.New(fmt.Sprintf("Unable to parse service %s as map for run item %s", service_name, name))
+			}
+
+			for k := range service_expanded {
+				key, ok := service_expanded[k].Key.(string)
 				if !ok {
-					return name, run, errors.New(fmt.Sprintf("Unable to parse Env as list for run item %s", name))
+					return services, errors.New(fmt.Sprintf("Unable to parse key as string for service %s in run item %s", service_name, name))
 				}
-				for _, s := range env_list {
-					t, ok := s.(string)
-					if ok {
-						run.Env = append(run.Env, t)
-					} else {
-						return name, run, errors.New(fmt.Sprintf("Unable to parse Env item as string for run item %s", name))
-					}
+
+				value := service_expanded[k].Value
+				field := fmt.Sprintf("service %s %s", service_name, key)
+
+				var err error
+				switch key {
+				case "Image":
+					service.Image, err = unmarshallString(value, field, name)
+				case "Cmd":
+					service.Cmd, err = unmarshallServiceCmd(value, field, name)
+				case "HealthCmd":
+					service.HealthCmd, err = unmarshallString(value, field, name)
+				case "Env":
+					service.Env, err = unmarshallStringList(value, field, name)
+				}
+				if err != nil {
+					return services, err
 				}
 			}
 		}
+
+		if service.Image == "" {
+			return services, errors.New(fmt.Sprintf("Image empty for service %s in run item %s", service_name, name))
+		}
+
+		services[service_name] = service
+	}
+
+	return services, nil
+}
+
+var paramNameRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+func unmarshallParams(value interface{}, name string) (map[string]*string, error) {
+	params := make(map[string]*string)
+
+	items, ok := value.(yaml.MapSlice)
+	if !ok {
+		return params, errors.New(fmt.Sprintf("Unable to parse Params as map for run item %s", name))
 	}
 
-	if run.Cmd == "" {
-		return name, run, errors.New(fmt.Sprintf("Cmd empty for %s", name))
+	for _, item := range items {
+		param, ok := item.Key.(string)
+		if !ok || !paramNameRegexp.MatchString(param) {
+			return params, errors.New(fmt.Sprintf("Invalid param name '%v' for run item %s", item.Key, name))
+		}
+
+		switch v := item.Value.(type) {
+		case nil:
+			// Param without default is required
+			params[param] = nil
+		case string, int, float64, bool:
+			value := fmt.Sprintf("%v", v)
+			params[param] = &value
+		default:
+			return params, errors.New(fmt.Sprintf("Unable to parse default of param %s as string for run item %s", param, name))
+		}
 	}
 
-	return name, run, nil
+	return params, nil
+}
+
+func validateRun(name string, run Run) error {
+	// Run items only grouping other run items don't need a command
+	if run.Cmd == "" && len(run.DependsOn) == 0 {
+		return errors.New(fmt.Sprintf("Cmd empty for %s", name))
+	}
+
+	if run.Image != "" && !utils.StringInSlice(run.Image, RunImages) {
+		return errors.New(fmt.Sprintf("Image must be one of %s for run item %s",
+			strings.Join(RunImages, ", "), name))
+	}
+
+	if run.Workdir != "" && !strings.HasPrefix(run.Workdir, "/") {
+		return errors.New(fmt.Sprintf("Workdir must be an absolute path for run item %s", name))
+	}
+
+	if strings.ContainsAny(run.Entrypoint, " \t") {
+		return errors.New(fmt.Sprintf("Entrypoint must be a single executable for run item %s", name))
+	}
+
+	for _, volume := range run.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || !strings.HasPrefix(parts[1], "/") {
+			return errors.New(fmt.Sprintf("Unable to parse Volumes item '%s' as host:container[:options] for run item %s", volume, name))
+		}
+	}
+
+	return nil
 }
 
 var unmarshallConfig = func(content string) (Config, error) {
+	config, err := parseConfig(content)
+	if err != nil {
+		return config, err
+	}
+
+	if err := validateConfig(config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Parse config without validating it, config is validated after all
+// config layers are merged
+func parseConfig(content string) (Config, error) {
 	type UnmarshalConfig struct {
-		Project Project       `yaml:"Project"`
-		Run     yaml.MapSlice `yaml:"Run,omitempty"`
+		Project      Project           `yaml:"Project"`
+		Versioning   *Versioning       `yaml:"Versioning,omitempty"`
+		TagTemplates []string          `yaml:"TagTemplates,omitempty"`
+		Build        *Build            `yaml:"Build,omitempty"`
+		Cache        *Cache            `yaml:"Cache,omitempty"`
+		Run          yaml.MapSlice     `yaml:"Run,omitempty"`
+		Secrets      map[string]Secret `yaml:"Secrets,omitempty"`
+		Projects     []SubProject      `yaml:"Projects,omitempty"`
 	}
 
 	uconfig := UnmarshalConfig{}
@@ -212,12 +489,22 @@ var unmarshallConfig = func(content string) (Config, error) {
 	// Get Project from unmarshalled config
 	config.Project = uconfig.Project
 
+	config.Versioning = uconfig.Versioning
+	config.TagTemplates = uconfig.TagTemplates
+	config.Build = uconfig.Build
+	config.Cache = uconfig.Cache
+
+	// Get Secrets from unmarshalled config
+	config.Secrets = uconfig.Secrets
+
+	config.Projects = uconfig.Projects
+
 	// Create Run map in config
 	config.Run = make(map[string]Run)
 
-	// Unmarshal every run item in Run map
+	// Parse every run item in Run map
 	for _, item := range uconfig.Run {
-		name, run, err := unmarshallConfigRun(item)
+		name, run, err := parseConfigRun(item)
 		if err != nil {
 			return config, err
 		}
@@ -227,86 +514,397 @@ var unmarshallConfig = func(content string) (Config, error) {
 	return config, nil
 }
 
-func loadConfigFile() (Config, error) {
-	// Get wrench file content
-	config_content, err := getConfigContent()
+func validateConfig(config Config) error {
+	if config.Versioning != nil && config.Versioning.Strategy != "" &&
+		!utils.StringInSlice(config.Versioning.Strategy, semver.Strategies) {
+		return errors.New(fmt.Sprintf("Versioning Strategy must be one of %s", strings.Join(semver.Strategies, ", ")))
+	}
+
+	if err := validateVersioningTags(config.Versioning); err != nil {
+		return err
+	}
+
+	if config.Project.Image != "" {
+		if _, err := image.Parse(config.Project.Image); err != nil {
+			return errors.New(fmt.Sprintf("Invalid Project Image: %s", err))
+		}
+	}
+
+	if err := validateBuild(config.Build); err != nil {
+		return err
+	}
+
+	if err := validateCache(config.Cache); err != nil {
+		return err
+	}
+
+	if err := validateSecrets(config.Secrets); err != nil {
+		return err
+	}
+
+	if err := validateProjects(config.Projects); err != nil {
+		return err
+	}
+
+	// Validate in a stable order to report the same error every time
+	var names []string
+	for name := range config.Run {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateRun(name, config.Run[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := validateRunDependencies(config.Run); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		for _, secret := range config.Run[name].Secrets {
+			if _, ok := config.Secrets[secret]; !ok {
+				return errors.New(fmt.Sprintf("Unknown secret %s for run item %s", secret, name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Secrets are passed through the environment of the docker client, names
+// used by the docker client itself are not allowed
+func validateSecrets(secrets map[string]Secret) error {
+	for name, secret := range secrets {
+		if !paramNameRegexp.MatchString(name) || strings.HasPrefix(name, "DOCKER_") ||
+			utils.StringInSlice(name, []string{"HOME", "PATH"}) {
+			return errors.New(fmt.Sprintf("Invalid secret name '%s'", name))
+		}
+
+		sources := 0
+		for _, source := range []string{secret.Env, secret.File, secret.Command} {
+			if source != "" {
+				sources += 1
+			}
+		}
+		if sources != 1 {
+			return errors.New(fmt.Sprintf("Secret %s needs exactly one of Env, File or Command", name))
+		}
+	}
+
+	return nil
+}
+
+// Make sure all run dependencies exist and that there are no cycles
+func validateRunDependencies(runs map[string]Run) error {
+	// Iterate in a stable order to report the same cycle every time
+	var names []string
+	for name := range runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+
+		switch state[name] {
+		case visiting:
+			// Only report the part of the path that is the cycle
+			for i, p := range path {
+				if p == name {
+					path = path[i:]
+					break
+				}
+			}
+			return errors.New(fmt.Sprintf("Dependency cycle in run items: %s", strings.Join(path, " -> ")))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, dep := range runs[name].DependsOn {
+			if _, ok := runs[dep]; !ok {
+				return errors.New(fmt.Sprintf("Unknown dependency %s for run item %s", dep, name))
+			}
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolved) loadConfigFile() (Config, error) {
+	config := Config{}
+
+	// Merge config files in order of precedence
+	for _, file := range r.getConfigFiles() {
+		layer, err := r.loadConfigLayer(file, nil, config.Project, nil)
+		if err != nil {
+			return Config{}, err
+		}
+
+		// Projects in a monorepo share the top-level config
+		if file == r.monorepo_config_file {
+			layer = getMonorepoDefaults(layer)
+		}
+
+		config = mergeConfig(config, layer)
+	}
+
+	// Environment variables override all config files
+	r.applyEnvOverrides(&config)
+
+	if err := validateConfig(config); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// Load single config file and the files it includes without validating
+// the merged result. Included files are merged in order and the including
+// file overrides them. Project holds values from earlier layers available
+// to templates. Files from a git repository source are rendered without
+// access to the host and may only set values that stay inside containers.
+func (r *Resolved) loadConfigLayer(file string, including []string, project Project, source *includeSource) (Config, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return Config{}, err
+	}
 
+	for i, f := range including {
+		if f == abs {
+			var cycle []string
+			for _, c := range append(append([]string{}, including[i:]...), abs) {
+				cycle = append(cycle, r.getDisplayFileName(c))
+			}
+			return Config{}, errors.New(fmt.Sprintf("Include cycle: %s", strings.Join(cycle, " -> ")))
+		}
+	}
+
+	content, err := getConfigContent(file)
 	if err != nil {
 		return Config{}, err
 	}
 
 	// Return if config file content is empty
-	if config_content == "" {
+	if content == "" {
 		return Config{}, nil
 	}
 
-	config_rendered, err := getRenderedConfigContent(config_content)
+	var rendered string
+	if source != nil {
+		rendered, err = r.renderUntrusted(content, project)
+	} else {
+		rendered, err = r.render(content, project)
+	}
 	if err != nil {
 		return Config{}, err
 	}
 
-	// Return if rendered_config content is empty
-	if config_rendered == "" {
+	// Return if rendered content is empty
+	if rendered == "" {
 		return Config{}, nil
 	}
 
-	config, err := unmarshallConfig(config_rendered)
+	name := r.getDisplayFileName(file)
+
+	if err := validateConfigContent(name, rendered); err != nil {
+		return Config{}, err
+	}
+
+	includes, err := parseIncludes(rendered)
+	if err != nil {
+		return Config{}, errors.New(fmt.Sprintf("%s: %s", name, err))
+	}
+
+	layer, err := parseConfig(rendered)
 	if err != nil {
 		return Config{}, err
 	}
 
-	return config, nil
+	if source != nil {
+		if err := checkGitIncludeConfig(layer); err != nil {
+			return Config{}, errors.New(fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	// Included files see Project values of the including file
+	project = mergeConfig(Config{Project: project}, layer).Project
+
+	config := Config{}
+	for _, include := range includes {
+		path, include_source, err := r.resolveInclude(include, abs, source)
+		if err != nil {
+			return Config{}, err
+		}
+		if !utils.FileExists(path) {
+			return Config{}, errors.New(fmt.Sprintf("%s: Included file %s not found", name, include.Path))
+		}
+
+		included, err := r.loadConfigLayer(path, append(including, abs), project, include_source)
+		if err != nil {
+			return Config{}, err
+		}
+		config = mergeConfig(config, included)
+	}
+
+	r.setFileOrigins(name, rendered)
+
+	return mergeConfig(config, layer), nil
 }
 
-func GetProjectOrganization() string {
-	if config.Project.Organization == "" {
-		config.Project.Organization = detectProjectOrganization()
+// Validate config against schema. Unknown keys are only reported as warnings
+// to not break configs written for newer versions of wrench.
+var validateConfigContent = func(file string, content string) error {
+	validation_errors, err := Validate(file, content)
+	if err != nil {
+		return err
 	}
-	return config.Project.Organization
+
+	var messages []string
+	for _, e := range validation_errors {
+		if e.UnknownKey {
+			log.Warningf("%s", e.Error())
+		} else {
+			messages = append(messages, e.Error())
+		}
+	}
+
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "\n"))
+	}
+
+	return nil
 }
 
-func GetProjectName() string {
-	if config.Project.Name == "" {
-		config.Project.Name = detectProjectName()
+// Get file name relative to project directory if inside it, for messages
+func (r *Resolved) getDisplayFileName(file string) string {
+	if rel, err := filepath.Rel(r.dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
 	}
-	return config.Project.Name
+	return file
+}
+
+// Detect project values not set in config, image is derived from the
+// other values
+func (r *Resolved) resolveProject() error {
+	if r.config.Project.Organization == "" {
+		org, err := r.detectProjectOrganization()
+		if err != nil {
+			return err
+		}
+		r.config.Project.Organization = org
+		r.setOrigin("Project.Organization", "detected from hostname")
+	}
+
+	if r.config.Project.Name == "" {
+		r.config.Project.Name = r.detectProjectName()
+		r.setOrigin("Project.Name", "detected from project directory")
+	}
+
+	if r.config.Project.Version == "" {
+		version, err := r.detectProjectVersion()
+		if err != nil {
+			return err
+		}
+		r.config.Project.Version = version
+	}
+
+	if r.config.Project.Image == "" {
+		// Version and name may come from branch names or environment
+		ref, err := image.New(
+			fmt.Sprintf("%s/%s", r.config.Project.Organization, r.config.Project.Name),
+			r.config.Project.Version)
+		if err != nil {
+			return err
+		}
+		r.config.Project.Image = ref.String()
+		r.setOrigin("Project.Image", "derived from Organization, Name and Version")
+	}
+
+	return nil
+}
+
+func (r *Resolved) GetProjectOrganization() string {
+	return r.config.Project.Organization
+}
+
+func (r *Resolved) GetProjectName() string {
+	return r.config.Project.Name
+}
+
+func (r *Resolved) GetProjectVersion() string {
+	return r.config.Project.Version
+}
+
+func (r *Resolved) GetProjectImage() string {
+	return r.config.Project.Image
 }
 
-func GetProjectVersion() string {
-	if config.Project.Version == "" {
-		config.Project.Version = detectProjectVersion()
+// Get versioning with defaults for values not set
+func (r *Resolved) GetVersioning() Versioning {
+	versioning := Versioning{}
+	if r.config.Versioning != nil {
+		versioning = *r.config.Versioning
+	}
+	if versioning.Strategy == "" {
+		versioning.Strategy = semver.StrategyDescribe
+	}
+	if len(versioning.MainBranches) == 0 {
+		versioning.MainBranches = DefaultMainBranches
 	}
-	return config.Project.Version
+	return versioning
 }
 
-func GetProjectImage() string {
-	if config.Project.Image == "" {
-		config.Project.Image = fmt.Sprintf("%s/%s:%s",
-			GetProjectOrganization(),
-			GetProjectName(),
-			GetProjectVersion())
+// Format revision as version with versioning strategy of project
+func (r *Resolved) FormatVersion(revision semver.Revision) (string, error) {
+	versioning := r.GetVersioning()
+
+	if versioning.Strategy == semver.StrategyBranchSha && revision.Branch == "" {
+		if branch := r.git.Branch(); !utils.StringInSlice(branch, versioning.MainBranches) {
+			revision.Branch = branch
+		}
 	}
-	return config.Project.Image
+
+	return semver.Format(versioning.Strategy, revision)
 }
 
-func GetRun(name string) (Run, bool) {
-	val, ok := config.Run[name]
+func (r *Resolved) GetRun(name string) (Run, bool) {
+	val, ok := r.config.Run[name]
 	return val, ok
 }
 
-var getHostname = func() (string, error) {
-	exitcode, out := runCmd("hostname -f")
-	if exitcode != 0 {
-		return "", errors.New(fmt.Sprintf("hostname exited with %d", exitcode))
-	}
-	return out, nil
+func (r *Resolved) GetSecret(name string) (Secret, bool) {
+	val, ok := r.config.Secrets[name]
+	return val, ok
 }
 
-func detectProjectOrganization() string {
-	hostname, err := getHostname()
+func (r *Resolved) detectProjectOrganization() (string, error) {
+	hostname, err := r.host.Hostname()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "", err
 	}
 
 	parts := strings.Split(string(hostname), ".")
@@ -320,119 +918,79 @@ func detectProjectOrganization() string {
 		org = parts[len(parts)-2]
 	}
 	org = strings.TrimSpace(org)
-	return org
-}
-
-var getAbsFilePath = func() string {
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		panic(err)
-	}
-	return dir
+	return org, nil
 }
 
-func detectProjectName() string {
-	dir := getAbsFilePath()
-	if project := string(filepath.Base(dir)); project == "/" {
+func (r *Resolved) detectProjectName() string {
+	if project := string(filepath.Base(r.dir)); project == "/" {
 		return "noname"
 	} else {
 		return project
 	}
 }
 
-var runCmd = func(command string) (int, string) {
-	return utils.RunCmd(command)
-}
-
-var getGitRepoPresent = func() (bool, error) {
-	exitcode, out := runCmd("git rev-parse --short HEAD")
-	if exitcode == 127 {
-		return false, errors.New("No git executable found")
-	} else if exitcode == 128 {
-		return false, errors.New("Not a git repository")
-	} else if exitcode != 0 {
-		return false, errors.New(out)
-	}
-	return true, nil
-}
-
-var getGitSemverTag = func() (string, error) {
-	// get git describe but only on semver tags
-	exitcode, out := runCmd("git describe --tags --match v*.*.*")
-	if exitcode == 128 {
-		// No version tag found, generate initial version
-		return "", errors.New("No semver formatted git tag found")
-	} else if exitcode != 0 {
-		return "", errors.New(out)
-	} else if out == "" {
-		return "", errors.New("Empty output from git describe")
+// Get version of nearest version tag from git describe
+func (r *Resolved) getGitSemverTag() (string, error) {
+	out, err := r.git.Describe(r.GetTagPattern())
+	if err != nil {
+		return "", err
 	}
 
-	version := strings.TrimSpace(string(out))
-	return version, nil
+	// Version without tag prefix
+	return r.parseTagVersion(out)
 }
 
-func detectProjectVersion() string {
+func (r *Resolved) detectProjectVersion() (string, error) {
 	// make sure git is installed and we are inside a git repo
-	if present, err := getGitRepoPresent(); !present {
-		fmt.Println(err)
-		os.Exit(1)
+	if present, err := r.git.Present(); !present {
+		return "", err
 	}
 
 	// get latest git semver version
-	if version, err := getGitSemverTag(); err != nil {
-		return generateInitialVersion()
+	var version string
+	if v, err := r.getGitSemverTag(); err != nil {
+		r.setOrigin("Project.Version", "generated from git commit count")
+		if version, err = r.generateInitialVersion(); err != nil {
+			return "", err
+		}
 	} else {
-		return version
+		r.setOrigin("Project.Version", "detected from git describe")
+		version = v
 	}
-}
 
-var getGitCommitCount = func() (int, error) {
-	exitcode, out := runCmd("git rev-list HEAD --count")
-	if exitcode != 0 {
-		return 0, errors.New(out)
+	strategy := r.GetVersioning().Strategy
+	if strategy == semver.StrategyDescribe {
+		return version, nil
 	}
 
-	num, err := strconv.Atoi(strings.TrimSpace(out))
+	// Format git describe version with versioning strategy
+	revision, err := semver.ParseDescribe(version)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	// Get number of commits since initial commit instead of total
-	num -= 1
-
-	return num, nil
-}
-
-var getGitShortSha = func() (string, error) {
-	exitcode, out := runCmd("git rev-parse --short HEAD")
-	if exitcode == 128 {
-		return "", errors.New("No semver formatted git tag found")
-	} else if exitcode != 0 {
-		return "", errors.New(out)
-	} else if out == "" {
-		return "", errors.New("Empty output from git rev-parse")
+	if version, err = r.FormatVersion(revision); err != nil {
+		return "", err
 	}
-	return strings.TrimSpace(string(out)), nil
+	r.setOrigin("Project.Version", fmt.Sprintf("%s with versioning strategy %s", r.origins["Project.Version"], strategy))
+
+	return version, nil
 }
 
-var generateInitialVersion = func() string {
+func (r *Resolved) generateInitialVersion() (string, error) {
 	// Get number of commits
-	num_commits, err := getGitCommitCount()
-
+	num_commits, err := r.git.CommitCount()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Get short git sha
-	git_short, err := getGitShortSha()
+	git_short, err := r.git.ShortSha()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Create a git describe like snapshot version
 	var version = fmt.Sprintf("v0.0.0-%d-g%s", num_commits, git_short)
-	return version
+	return version, nil
 }
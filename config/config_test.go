@@ -1,13 +1,108 @@
 package config
 
-var mocked_functions = map[string]interface{}{
-	"getAbsFilePath":         getAbsFilePath,
-	"generateInitialVersion": generateInitialVersion,
-	"getHostname":            getHostname,
-	"getGitCommitCount":      getGitCommitCount,
-	"getGitSemverTag":        getGitSemverTag,
-	"getGitShortSha":         getGitShortSha,
-	"getGitRepoPresent":      getGitRepoPresent,
-	"runCmd":                 runCmd,
-	"unmarshallConfigRun":    unmarshallConfigRun,
+import (
+	"errors"
+	"path"
+)
+
+// Git of a repository without uncommitted changes, describe is empty if
+// there is no version tag
+type fakeGit struct {
+	sha          string
+	short_sha    string
+	branch       string
+	tag          string
+	describe     string
+	tags         []string
+	commit_count int
+	changed      []string
+	changed_err  error
+	fetch        func(repo string, ref string, dir string) error
+}
+
+func (g *fakeGit) Present() (bool, error) {
+	return true, nil
+}
+
+func (g *fakeGit) Sha() (string, error) {
+	if g.sha == "" {
+		return "", errors.New("Not a git repository")
+	}
+	return g.sha, nil
+}
+
+func (g *fakeGit) ShortSha() (string, error) {
+	if g.short_sha == "" {
+		return "", errors.New("Empty output from git rev-parse")
+	}
+	return g.short_sha, nil
+}
+
+func (g *fakeGit) Branch() string {
+	return g.branch
+}
+
+func (g *fakeGit) ExactTag() string {
+	return g.tag
+}
+
+func (g *fakeGit) Dirty() bool {
+	return false
+}
+
+func (g *fakeGit) Describe(pattern string) (string, error) {
+	if g.describe == "" {
+		return "", errors.New("No semver formatted git tag found")
+	}
+	return g.describe, nil
+}
+
+func (g *fakeGit) Tags(pattern string) ([]string, error) {
+	var tags []string
+	for _, tag := range g.tags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (g *fakeGit) CommitCount() (int, error) {
+	return g.commit_count, nil
+}
+
+func (g *fakeGit) ChangedFiles(ref string) ([]string, error) {
+	return g.changed, g.changed_err
+}
+
+func (g *fakeGit) Fetch(repo string, ref string, dir string) error {
+	if g.fetch == nil {
+		return errors.New("Unable to fetch")
+	}
+	return g.fetch(repo, ref, dir)
+}
+
+type fakeHost struct {
+	hostname string
+}
+
+func (h fakeHost) Hostname() (string, error) {
+	return h.hostname, nil
+}
+
+type fakeEnv []string
+
+func (e fakeEnv) Environ() []string {
+	return e
+}
+
+// Get resolved context of config in dir with fake dependencies, git is a
+// repository without tags if nil
+func newTestResolved(dir string, config Config, git *fakeGit, environ ...string) *Resolved {
+	if git == nil {
+		git = &fakeGit{}
+	}
+	r := newResolved(dir, Options{Git: git, Host: fakeHost{"host.example.com"}, Env: fakeEnv(environ)})
+	r.config = config
+	return r
 }
@@ -15,90 +15,95 @@ func TestDetectTestSuite(t *testing.T) {
 	suite.Run(t, new(DetectTestSuite))
 }
 
-func (suite *DetectTestSuite) SetupSuite() {
-	getGitRepoPresent = func() (bool, error) { return true, nil }
-}
-
-func (suite *DetectTestSuite) TearDownSuite() {
-	getGitRepoPresent = mocked_functions["getGitRepoPresent"].(func() (bool, error))
-}
-
-func (suite *DetectTestSuite) TearDownTest() {
-	getAbsFilePath = mocked_functions["getAbsFilePath"].(func() string)
-	getHostname = mocked_functions["getHostname"].(func() (string, error))
-	runCmd = mocked_functions["runCmd"].(func(string) (int, string))
-	getGitSemverTag = mocked_functions["getGitSemverTag"].(func() (string, error))
-	generateInitialVersion = mocked_functions["generateInitialVersion"].(func() string)
-}
-
 func (suite *DetectTestSuite) TestDetectProjectName() {
-	getAbsFilePath = func() string {
-		return "/foobar"
-	}
-	assert.Equal(suite.T(), "foobar", detectProjectName())
+	r := newTestResolved("/foobar", Config{}, nil)
+	assert.Equal(suite.T(), "foobar", r.detectProjectName())
 }
 
 func (suite *DetectTestSuite) TestDetectProjectNameRoot() {
-	getAbsFilePath = func() string {
-		return "/"
-	}
-	assert.Equal(suite.T(), "noname", detectProjectName())
+	r := newTestResolved("/", Config{}, nil)
+	assert.Equal(suite.T(), "noname", r.detectProjectName())
 }
 
 func (suite *DetectTestSuite) TestDetectProjectNameSub() {
-	getAbsFilePath = func() string {
-		return "/home/root/aoeu1234"
-	}
-	assert.Equal(suite.T(), "aoeu1234", detectProjectName())
+	r := newTestResolved("/home/root/aoeu1234", Config{}, nil)
+	assert.Equal(suite.T(), "aoeu1234", r.detectProjectName())
+}
+
+func (suite *DetectTestSuite) detectOrganization(hostname string) string {
+	r := newResolved("/foobar", Options{Git: &fakeGit{}, Host: fakeHost{hostname}, Env: fakeEnv{}})
+	return mustDetect(suite.T(), r.detectProjectOrganization)
 }
 
 func (suite *DetectTestSuite) TestDetectProjectOrganizationSingle() {
-	getHostname = func() (string, error) {
-		return "user", nil
-	}
-	assert.Equal(suite.T(), "user", detectProjectOrganization())
+	assert.Equal(suite.T(), "user", suite.detectOrganization("user"))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectOrganizationLocal() {
-	getHostname = func() (string, error) {
-		return "hostname.domain", nil
-	}
-	assert.Equal(suite.T(), "domain", detectProjectOrganization())
+	assert.Equal(suite.T(), "domain", suite.detectOrganization("hostname.domain"))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectOrganizationDomain() {
-	getHostname = func() (string, error) {
-		return "hostname.domain.topdomain", nil
-	}
-	assert.Equal(suite.T(), "domain", detectProjectOrganization())
+	assert.Equal(suite.T(), "domain", suite.detectOrganization("hostname.domain.topdomain"))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectOrganizationSubDomain() {
-	getHostname = func() (string, error) {
-		return "hostname.subdomain.domain.topdomain", nil
-	}
-	assert.Equal(suite.T(), "domain", detectProjectOrganization())
+	assert.Equal(suite.T(), "domain", suite.detectOrganization("hostname.subdomain.domain.topdomain\n"))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectVersion() {
-	runCmd = func(string) (int, string) {
-		return 0, "v0.1.0-1-g1234567"
-	}
-	assert.Equal(suite.T(), "v0.1.0-1-g1234567", detectProjectVersion())
+	r := newTestResolved("/foobar", Config{}, &fakeGit{describe: "v0.1.0-1-g1234567"})
+	assert.Equal(suite.T(), "v0.1.0-1-g1234567", mustDetect(suite.T(), r.detectProjectVersion))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectVersionRelease() {
-	runCmd = func(string) (int, string) {
-		return 0, "v123.456.789"
-	}
-	assert.Equal(suite.T(), "v123.456.789", detectProjectVersion())
+	r := newTestResolved("/foobar", Config{}, &fakeGit{describe: "v123.456.789"})
+	assert.Equal(suite.T(), "v123.456.789", mustDetect(suite.T(), r.detectProjectVersion))
 }
 
 func (suite *DetectTestSuite) TestDetectProjectVersionNoTag() {
-	runCmd = func(string) (int, string) {
-		return 128, "No semver formatted git tag found"
+	r := newTestResolved("/foobar", Config{}, &fakeGit{short_sha: "abc1234", commit_count: 3})
+
+	assert.Equal(suite.T(), "v0.0.0-3-gabc1234", mustDetect(suite.T(), r.detectProjectVersion))
+	assert.Equal(suite.T(), "generated from git commit count", r.origins["Project.Version"])
+}
+
+func (suite *DetectTestSuite) TestDetectProjectVersionStrategy() {
+	r := newTestResolved("/foobar", Config{Versioning: &Versioning{Strategy: "pep440"}}, &fakeGit{describe: "v0.1.0-1-g1234567"})
+
+	assert.Equal(suite.T(), "0.1.1.dev1+g1234567", mustDetect(suite.T(), r.detectProjectVersion))
+}
+
+func (suite *DetectTestSuite) TestDetectProjectVersionBranchSha() {
+	r := newTestResolved("/foobar", Config{Versioning: &Versioning{Strategy: "branch-sha"}},
+		&fakeGit{describe: "v0.1.0-1-g1234567", branch: "feature/foo"})
+
+	assert.Equal(suite.T(), "v0.1.0-feature-foo.1.g1234567", mustDetect(suite.T(), r.detectProjectVersion))
+}
+
+func (suite *DetectTestSuite) TestDetectProjectVersionBranchShaMain() {
+	r := newTestResolved("/foobar", Config{Versioning: &Versioning{Strategy: "branch-sha"}},
+		&fakeGit{describe: "v0.1.0-1-g1234567", branch: "main"})
+
+	assert.Equal(suite.T(), "v0.1.0-1-g1234567", mustDetect(suite.T(), r.detectProjectVersion))
+}
+
+func (suite *DetectTestSuite) TestLoad() {
+	dir := suite.T().TempDir()
+	git := &fakeGit{describe: "v1.2.3"}
+
+	r, err := Load(dir, Options{Git: git, Host: fakeHost{"host.acme.com"}, Env: fakeEnv{"WRENCH_PROJECT_NAME=api"}})
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), dir, r.Dir())
+		assert.Equal(suite.T(), "acme/api:v1.2.3", r.GetProjectImage())
+		assert.Equal(suite.T(), "env WRENCH_PROJECT_NAME", r.origins["Project.Name"])
 	}
-	generateInitialVersion = func() string { return "generated-version" }
+}
 
-	assert.Equal(suite.T(), "generated-version", detectProjectVersion())
+// Get detected value, failing test on error
+func mustDetect(t *testing.T, detect func() (string, error)) string {
+	value, err := detect()
+	assert.Nil(t, err)
+	return value
 }
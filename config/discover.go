@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const configFileName = "wrench.yml"
+
+// Find project root by walking up from dir to the nearest directory with a
+// wrench.yml, or the git root if there is none. The walk stops at the git
+// root, so config files above the repository are not used. Returns dir
+// itself if neither is found.
+func findProjectRoot(dir string) (string, string) {
+	for current := dir; ; current = filepath.Dir(current) {
+		if path := filepath.Join(current, configFileName); utils.FileExists(path) {
+			return current, path
+		}
+
+		if utils.FileExists(filepath.Join(current, ".git")) {
+			return current, ""
+		}
+
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+
+	return dir, ""
+}
+
+// Find project directory and config file from dir, file overrides the
+// discovered config file and is relative to dir
+func (r *Resolved) discover(dir string, file string) error {
+	r.dir = dir
+	r.config_file = ""
+	r.monorepo_config_file = ""
+
+	// Explicit config file, project directory is dir
+	if file != "" {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, file)
+		}
+		if !utils.FileExists(path) {
+			return errors.New(fmt.Sprintf("Config file %s not found", file))
+		}
+		r.config_file = path
+		return nil
+	}
+
+	root, path := findProjectRoot(dir)
+
+	// Project in monorepo without a config file of its own
+	if project_dir, file := r.findMonorepoProject(dir); file != "" {
+		r.monorepo_config_file = file
+		if !strings.HasPrefix(root+string(filepath.Separator), project_dir+string(filepath.Separator)) || path == file {
+			root = project_dir
+			path = ""
+			if project_file := filepath.Join(project_dir, configFileName); utils.FileExists(project_file) {
+				path = project_file
+			}
+		}
+	}
+
+	r.dir = root
+	r.config_file = path
+
+	return nil
+}
+
+func (r *Resolved) setOrigin(key string, origin string) {
+	r.origins[key] = origin
+}
+
+// Set origin of every value in config file to file and line
+func (r *Resolved) setFileOrigins(file string, content string) {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(content), &document); err != nil || len(document.Content) == 0 {
+		return
+	}
+
+	var walk func(node *yamlv3.Node, path string, depth int)
+	walk = func(node *yamlv3.Node, path string, depth int) {
+		if node.Kind != yamlv3.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			key_path := key.Value
+			if path != "" {
+				key_path = path + "." + key.Value
+			}
+
+			// Project values and Run items, not every key of a run item
+			if depth > 0 {
+				r.setOrigin(key_path, fmt.Sprintf("%s:%d", file, key.Line))
+			}
+			if depth == 0 {
+				walk(node.Content[i+1], key_path, depth+1)
+			}
+		}
+	}
+	walk(document.Content[0], "", 0)
+}
+
+// Get origin of every config value as "origin<TAB>key=value" lines
+func (r *Resolved) ShowOrigin() string {
+	values := map[string]string{
+		"Project.Organization": r.GetProjectOrganization(),
+		"Project.Name":         r.GetProjectName(),
+		"Project.Version":      r.GetProjectVersion(),
+		"Project.Image":        r.GetProjectImage(),
+	}
+	for name, run := range r.config.Run {
+		values["Run."+name] = run.Cmd
+	}
+	for name := range r.config.Secrets {
+		values["Secrets."+name] = "***"
+	}
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, key := range keys {
+		origin, ok := r.origins[key]
+		if !ok {
+			origin = "unknown"
+		}
+
+		// Only show first line of multiline run commands
+		value := strings.SplitN(values[key], "\n", 2)[0]
+
+		lines = append(lines, fmt.Sprintf("%s\t%s=%s", origin, key, value))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiscoverTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestDiscoverTestSuite(t *testing.T) {
+	suite.Run(t, new(DiscoverTestSuite))
+}
+
+func (suite *DiscoverTestSuite) SetupTest() {
+	// Resolve symlinks since os.Getwd returns resolved path
+	dir, _ := filepath.EvalSymlinks(suite.T().TempDir())
+	suite.dir = dir
+
+	os.MkdirAll(filepath.Join(dir, "repo", ".git"), 0755)
+	os.MkdirAll(filepath.Join(dir, "repo", "service", "src"), 0755)
+	os.MkdirAll(filepath.Join(dir, "repo", "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "repo", "service", "wrench.yml"), []byte("Project:\n  Name: service\n"), 0644)
+}
+
+func (suite *DiscoverTestSuite) TestFindProjectRootConfig() {
+	root, file := findProjectRoot(filepath.Join(suite.dir, "repo", "service", "src"))
+
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo", "service"), root)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo", "service", "wrench.yml"), file)
+}
+
+func (suite *DiscoverTestSuite) TestFindProjectRootGit() {
+	root, file := findProjectRoot(filepath.Join(suite.dir, "repo", "docs"))
+
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo"), root)
+	assert.Equal(suite.T(), "", file)
+}
+
+func (suite *DiscoverTestSuite) TestFindProjectRootGitInsideProject() {
+	ioutil.WriteFile(filepath.Join(suite.dir, "wrench.yml"), []byte("Project:\n  Name: outer\n"), 0644)
+
+	root, file := findProjectRoot(filepath.Join(suite.dir, "repo", "docs"))
+
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo"), root)
+	assert.Equal(suite.T(), "", file)
+}
+
+func (suite *DiscoverTestSuite) TestFindProjectRootNone() {
+	root, file := findProjectRoot(suite.dir)
+
+	assert.Equal(suite.T(), suite.dir, root)
+	assert.Equal(suite.T(), "", file)
+}
+
+func (suite *DiscoverTestSuite) TestDiscoverProjectDirectory() {
+	r := newTestResolved(suite.dir, Config{}, nil)
+
+	err := r.discover(filepath.Join(suite.dir, "repo", "service", "src"), "")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo", "service", "wrench.yml"), r.config_file)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "repo", "service"), r.Dir())
+}
+
+func (suite *DiscoverTestSuite) TestDiscoverProjectConfigFile() {
+	dir := filepath.Join(suite.dir, "repo", "service")
+
+	file, err := Discover(dir, Options{ConfigFile: "wrench.yml", Git: &fakeGit{}, Env: fakeEnv{}})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), filepath.Join(dir, "wrench.yml"), file)
+}
+
+func (suite *DiscoverTestSuite) TestDiscoverProjectConfigNotFound() {
+	r := newTestResolved(suite.dir, Config{}, nil)
+
+	err := r.discover(suite.dir, "missing.yml")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Config file missing.yml not found", err.Error())
+	}
+}
+
+func (suite *DiscoverTestSuite) TestSetFileOrigins() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		"\n" +
+		"  Version: v1.0.0\n" +
+		"Run:\n" +
+		"  unit:\n" +
+		"    Cmd: pytest\n"
+
+	r := newTestResolved(suite.dir, Config{}, nil)
+	r.setFileOrigins("wrench.yml", content)
+
+	assert.Equal(suite.T(), map[string]string{
+		"Project.Name":    "wrench.yml:2",
+		"Project.Version": "wrench.yml:4",
+		"Run.unit":        "wrench.yml:6",
+	}, r.origins)
+}
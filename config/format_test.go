@@ -1,6 +1,8 @@
 package config
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,6 +11,7 @@ import (
 
 type FormatTestSuite struct {
 	suite.Suite
+	r *Resolved
 }
 
 func TestFormatTestSuite(t *testing.T) {
@@ -17,7 +20,7 @@ func TestFormatTestSuite(t *testing.T) {
 
 func (suite *FormatTestSuite) SetupSuite() {
 	// Setup prefined data in config for format test
-	config = &Config{
+	suite.r = newTestResolved("/foobar", Config{
 		Project: Project{
 			Organization: "example",
 			Name:         "foobar",
@@ -26,12 +29,14 @@ func (suite *FormatTestSuite) SetupSuite() {
 		Run: map[string]Run{
 			"syntax-test": {Cmd: "flake8 -v ."},
 		},
-	}
+	}, nil)
+	assert.Nil(suite.T(), suite.r.resolveProject())
 }
 
-func (suite *FormatTestSuite) TearDownSuite() {
-	// Cleanup state after suite
-	config = &Config{}
+func (suite *FormatTestSuite) formatConfig(format string) string {
+	out, err := suite.r.FormatConfig(format)
+	assert.Nil(suite.T(), err)
+	return out
 }
 
 func (suite *FormatTestSuite) TestConfigCommand() {
@@ -43,21 +48,104 @@ func (suite *FormatTestSuite) TestConfigCommand() {
 		"Run:\n" +
 		"  syntax-test:\n" +
 		"    Cmd: flake8 -v .\n"
-	assert.Equal(suite.T(), expected, commandConfig(""))
+	assert.Equal(suite.T(), expected, suite.formatConfig(""))
 }
 
 func (suite *FormatTestSuite) TestConfigCommandFormatProject() {
-	assert.Equal(suite.T(), "{example foobar v1.0.0 example/foobar:v1.0.0}", commandConfig("{{.Project}}"))
+	assert.Equal(suite.T(), "{example foobar v1.0.0 example/foobar:v1.0.0}", suite.formatConfig("{{.Project}}"))
 }
 
 func (suite *FormatTestSuite) TestConfigCommandFormatProjectName() {
-	assert.Equal(suite.T(), "foobar", commandConfig("{{.Project.Name}}"))
+	assert.Equal(suite.T(), "foobar", suite.formatConfig("{{.Project.Name}}"))
 }
 
 func (suite *FormatTestSuite) TestConfigCommandFormatProjectVersion() {
-	assert.Equal(suite.T(), "v1.0.0", commandConfig("{{.Project.Version}}"))
+	assert.Equal(suite.T(), "v1.0.0", suite.formatConfig("{{.Project.Version}}"))
 }
 
 func (suite *FormatTestSuite) TestConfigCommandFormatProjectImage() {
-	assert.Equal(suite.T(), "example/foobar:v1.0.0", commandConfig("{{.Project.Image}}"))
+	assert.Equal(suite.T(), "example/foobar:v1.0.0", suite.formatConfig("{{.Project.Image}}"))
+}
+
+func (suite *FormatTestSuite) TestConfigCommandFormatFunctions() {
+	assert.Equal(suite.T(), "FOOBAR", suite.formatConfig("{{ .Project.Name | upper }}"))
+}
+
+func (suite *FormatTestSuite) TestConfigOutputJson() {
+	expected := "{\n" +
+		"  \"Project\": {\n" +
+		"    \"Organization\": \"example\",\n" +
+		"    \"Name\": \"foobar\",\n" +
+		"    \"Version\": \"v1.0.0\",\n" +
+		"    \"Image\": \"example/foobar:v1.0.0\"\n" +
+		"  },\n" +
+		"  \"Run\": {\n" +
+		"    \"syntax-test\": {\n" +
+		"      \"Cmd\": \"flake8 -v .\"\n" +
+		"    }\n" +
+		"  }\n" +
+		"}"
+
+	out, err := suite.r.OutputConfig("json")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), expected, out)
+}
+
+func (suite *FormatTestSuite) TestConfigOutputEnv() {
+	expected := "WRENCH_CONFIG_PROJECT_ORGANIZATION=example\n" +
+		"WRENCH_CONFIG_PROJECT_NAME=foobar\n" +
+		"WRENCH_CONFIG_PROJECT_VERSION=v1.0.0\n" +
+		"WRENCH_CONFIG_PROJECT_IMAGE=example/foobar:v1.0.0\n" +
+		"WRENCH_CONFIG_PROJECT_BUILDER_IMAGE=example/foobar:v1.0.0-builder\n" +
+		"WRENCH_CONFIG_PROJECT_TEST_IMAGE=example/foobar:v1.0.0-test"
+
+	out, err := suite.r.OutputConfig("env")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), expected, out)
+}
+
+func (suite *FormatTestSuite) TestConfigOutputExport() {
+	out, err := suite.r.OutputConfig("export")
+
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), out, "export WRENCH_CONFIG_PROJECT_NAME='foobar'\n")
+}
+
+func (suite *FormatTestSuite) TestConfigOutputGithub() {
+	out, err := suite.r.OutputConfig("github")
+
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), out, "project_name=foobar\n")
+	assert.Contains(suite.T(), out, "project_test_image=example/foobar:v1.0.0-test")
+}
+
+func (suite *FormatTestSuite) TestConfigOutputUnknown() {
+	_, err := suite.r.OutputConfig("toml")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown output format toml, must be one of yaml, json, env, export, github", err.Error())
+	}
+}
+
+func (suite *FormatTestSuite) TestConfigOutputEnvNotLoadedAsOverride() {
+	dir := suite.T().TempDir()
+
+	r, err := Load(dir, Options{Git: &fakeGit{describe: "v0.0.0-0-g5e5e1c1"}, Host: fakeHost{"host.acme.com"}, Env: fakeEnv{}})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	out, err := r.OutputConfig("env")
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	// Project is tagged after values were exported
+	r, err = Load(dir, Options{Git: &fakeGit{describe: "v1.0.0"}, Host: fakeHost{"host.acme.com"}, Env: fakeEnv(strings.Split(out, "\n"))})
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "v1.0.0", r.GetProjectVersion())
+		assert.Equal(suite.T(), "acme/"+filepath.Base(dir)+":v1.0.0", r.GetProjectImage())
+	}
 }
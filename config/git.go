@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+)
+
+// Git repository of a project, used to detect project values and for git
+// values in templates
+type Git interface {
+	// Check that git is installed and the project is in a git repository
+	Present() (bool, error)
+
+	// Sha of HEAD
+	Sha() (string, error)
+	ShortSha() (string, error)
+
+	// Current branch, empty in detached HEAD
+	Branch() string
+
+	// Tag pointing at HEAD, empty if there is none
+	ExactTag() string
+
+	// Check if there are uncommitted changes
+	Dirty() bool
+
+	// Get git describe output for the nearest tag matching pattern
+	Describe(pattern string) (string, error)
+
+	// Get tags matching pattern
+	Tags(pattern string) ([]string, error)
+
+	// Get number of commits since the initial commit
+	CommitCount() (int, error)
+
+	// Get absolute paths of files changed since the merge base of ref and
+	// HEAD, including uncommitted changes
+	ChangedFiles(ref string) ([]string, error)
+
+	// Fetch single tag or commit of repository into dir. Branches are
+	// rejected, since a fetched ref is never refreshed.
+	Fetch(repo string, ref string, dir string) error
+}
+
+// Git using the git command in a directory
+type gitCommand struct {
+	// Run git with args, get exit code and output
+	run func(args ...string) (int, string)
+}
+
+func newGitCommand(dir string) *gitCommand {
+	return &gitCommand{run: func(args ...string) (int, string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if errors.Is(err, exec.ErrNotFound) {
+			return 127, err.Error()
+		} else if err != nil {
+			if exitcode := utils.GetCommandExitCode(err); exitcode != 0 {
+				return exitcode, string(out)
+			}
+			return 1, err.Error()
+		}
+		return 0, string(out)
+	}}
+}
+
+// Get trimmed output of git command, empty if it fails
+func (g *gitCommand) output(args ...string) string {
+	if exitcode, out := g.run(args...); exitcode == 0 {
+		return strings.TrimSpace(out)
+	}
+	return ""
+}
+
+func (g *gitCommand) Present() (bool, error) {
+	exitcode, out := g.run("rev-parse", "--short", "HEAD")
+	if exitcode == 127 {
+		return false, errors.New("No git executable found")
+	} else if exitcode == 128 {
+		return false, errors.New("Not a git repository")
+	} else if exitcode != 0 {
+		return false, errors.New(out)
+	}
+	return true, nil
+}
+
+func (g *gitCommand) Sha() (string, error) {
+	exitcode, out := g.run("rev-parse", "HEAD")
+	if exitcode != 0 {
+		return "", errors.New(out)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (g *gitCommand) ShortSha() (string, error) {
+	exitcode, out := g.run("rev-parse", "--short", "HEAD")
+	if exitcode == 128 {
+		return "", errors.New("No semver formatted git tag found")
+	} else if exitcode != 0 {
+		return "", errors.New(out)
+	} else if out == "" {
+		return "", errors.New("Empty output from git rev-parse")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g *gitCommand) Branch() string {
+	if branch := g.output("rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+		return branch
+	}
+	return ""
+}
+
+func (g *gitCommand) ExactTag() string {
+	return g.output("describe", "--tags", "--exact-match")
+}
+
+func (g *gitCommand) Dirty() bool {
+	return g.output("status", "--porcelain") != ""
+}
+
+func (g *gitCommand) Describe(pattern string) (string, error) {
+	// get git describe but only on version tags
+	exitcode, out := g.run("describe", "--tags", "--match", pattern)
+	if exitcode == 128 {
+		// No version tag found, generate initial version
+		return "", errors.New("No semver formatted git tag found")
+	} else if exitcode != 0 {
+		return "", errors.New(out)
+	} else if out == "" {
+		return "", errors.New("Empty output from git describe")
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (g *gitCommand) Tags(pattern string) ([]string, error) {
+	exitcode, out := g.run("tag", "-l", pattern)
+	if exitcode != 0 {
+		return nil, errors.New(fmt.Sprintf("%d: %s", exitcode, out))
+	}
+
+	var tags []string
+	for _, tag := range utils.RemoveEmptyStrings(strings.Split(out, "\n")) {
+		tags = append(tags, strings.TrimSpace(tag))
+	}
+	return tags, nil
+}
+
+func (g *gitCommand) CommitCount() (int, error) {
+	exitcode, out := g.run("rev-list", "HEAD", "--count")
+	if exitcode != 0 {
+		return 0, errors.New(out)
+	}
+
+	num, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, err
+	}
+
+	// Get number of commits since initial commit instead of total
+	num -= 1
+
+	return num, nil
+}
+
+func (g *gitCommand) ChangedFiles(ref string) ([]string, error) {
+	exitcode, out := g.run("rev-parse", "--show-toplevel")
+	if exitcode != 0 {
+		return nil, errors.New(out)
+	}
+	toplevel := strings.TrimSpace(out)
+
+	exitcode, out = g.run("merge-base", ref, "HEAD")
+	if exitcode != 0 {
+		return nil, errors.New(fmt.Sprintf("Unable to find merge base of %s and HEAD: %s", ref, strings.TrimSpace(out)))
+	}
+	merge_base := strings.TrimSpace(out)
+
+	exitcode, out = g.run("diff", "--name-only", merge_base)
+	if exitcode != 0 {
+		return nil, errors.New(out)
+	}
+
+	var files []string
+	for _, file := range utils.RemoveEmptyStrings(strings.Split(out, "\n")) {
+		files = append(files, filepath.Join(toplevel, strings.TrimSpace(file)))
+	}
+	return files, nil
+}
+
+func (g *gitCommand) Fetch(repo string, ref string, dir string) error {
+	for _, args := range [][]string{
+		{"init", "-q", dir},
+		{"-C", dir, "fetch", "-q", "--depth", "1", repo, ref},
+		{"-C", dir, "checkout", "-q", "FETCH_HEAD"},
+	} {
+		if exitcode, out := g.run(args...); exitcode != 0 {
+			return errors.New(fmt.Sprintf("Unable to fetch %s from %s: %s", ref, repo, strings.TrimSpace(out)))
+		}
+
+		// FETCH_HEAD describes the fetched ref, like branch 'main' of repo
+		if args[2] == "fetch" {
+			content, err := ioutil.ReadFile(filepath.Join(dir, ".git", "FETCH_HEAD"))
+			if err != nil {
+				return errors.New(fmt.Sprintf("Unable to fetch %s from %s: %s", ref, repo, err))
+			}
+			if strings.Contains(string(content), "\tbranch '") {
+				return errors.New(fmt.Sprintf("Ref %s of %s is a branch, pin include to a tag or commit", ref, repo))
+			}
+		}
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,18 +19,24 @@ func TestGitTestSuite(t *testing.T) {
 	suite.Run(t, new(GitTestSuite))
 }
 
-func (suite *GitTestSuite) TearDownTest() {
-	runCmd = mocked_functions["runCmd"].(func(string) (int, string))
+// Get version of nearest version tag found with git
+func describeVersion(git Git) (string, error) {
+	return newResolved("/foobar", Options{Git: git, Host: fakeHost{}, Env: fakeEnv{}}).getGitSemverTag()
+}
+
+// Get git with every command returning exitcode and out
+func fakeGitCommand(exitcode int, out string) *gitCommand {
+	return &gitCommand{run: func(args ...string) (int, string) {
+		return exitcode, out
+	}}
 }
 
 func (suite *GitTestSuite) TestGitCommitCount() {
 	for _, i := range []int{0, 1, 5, 10, 50, 99, 100, 1000, 10000} {
-		runCmd = func(string) (int, string) {
-			// return +1 since git cli returns total number of commits
-			return 0, fmt.Sprintf("%d", (i + 1))
-		}
+		// return +1 since git cli returns total number of commits
+		git := fakeGitCommand(0, fmt.Sprintf("%d", (i+1)))
 
-		num_commits, err := getGitCommitCount()
+		num_commits, err := git.CommitCount()
 
 		assert.Nil(suite.T(), err)
 		assert.Equal(suite.T(), i, num_commits)
@@ -35,111 +44,122 @@ func (suite *GitTestSuite) TestGitCommitCount() {
 }
 
 func (suite *GitTestSuite) TestGitCommitUnexpectedString() {
-	runCmd = func(string) (int, string) {
-		return 0, "foobar"
-	}
+	git := fakeGitCommand(0, "foobar")
 
-	num_commits, err := getGitCommitCount()
+	num_commits, err := git.CommitCount()
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), 0, num_commits)
 }
 
 func (suite *GitTestSuite) TestGitCommitExitCode() {
-	runCmd = func(string) (int, string) {
-		return 128, "FATAL: unexpected error"
-	}
+	git := fakeGitCommand(128, "FATAL: unexpected error")
 
-	num_commits, err := getGitCommitCount()
+	num_commits, err := git.CommitCount()
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), 0, num_commits)
 }
 
 func (suite *GitTestSuite) TestGitShortSha() {
-	runCmd = func(string) (int, string) {
-		return 0, "aoeu123"
-	}
+	git := fakeGitCommand(0, "aoeu123")
 
-	gitsha, err := getGitShortSha()
+	gitsha, err := git.ShortSha()
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), "aoeu123", gitsha)
 }
 
 func (suite *GitTestSuite) TestGitShortShaEmptyString() {
-	runCmd = func(string) (int, string) {
-		return 0, ""
-	}
+	git := fakeGitCommand(0, "")
 
-	gitsha, err := getGitShortSha()
+	gitsha, err := git.ShortSha()
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", gitsha)
 }
 
 func (suite *GitTestSuite) TestGitShortShaExitCode128() {
-	runCmd = func(string) (int, string) {
-		return 128, "no git semver tag found"
-	}
+	git := fakeGitCommand(128, "no git semver tag found")
 
-	gitsha, err := getGitShortSha()
+	gitsha, err := git.ShortSha()
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", gitsha)
 }
 
 func (suite *GitTestSuite) TestGitShortShaExitCodeUnspecific() {
-	runCmd = func(string) (int, string) {
-		return 2, "generic exit code"
-	}
+	git := fakeGitCommand(2, "generic exit code")
 
-	gitsha, err := getGitShortSha()
+	gitsha, err := git.ShortSha()
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", gitsha)
 }
 
 func (suite *GitTestSuite) TestGitSemverTag() {
-	runCmd = func(string) (int, string) {
-		return 0, "v123.456.789"
-	}
+	git := fakeGitCommand(0, "v123.456.789")
 
-	version, err := getGitSemverTag()
+	version, err := describeVersion(git)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), "v123.456.789", version)
 }
 
 func (suite *GitTestSuite) TestGitSemverTagEmptyString() {
-	runCmd = func(string) (int, string) {
-		return 0, ""
-	}
+	git := fakeGitCommand(0, "")
 
-	version, err := getGitSemverTag()
+	version, err := describeVersion(git)
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", version)
 }
 
 func (suite *GitTestSuite) TestGitSemverTagExitCode128() {
-	runCmd = func(string) (int, string) {
-		return 128, "no git semver tag found"
-	}
+	git := fakeGitCommand(128, "no git semver tag found")
 
-	version, err := getGitSemverTag()
+	version, err := describeVersion(git)
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", version)
 }
 
 func (suite *GitTestSuite) TestGitSemverTagExitCodeUnspecific() {
-	runCmd = func(string) (int, string) {
-		return 2, "generic exit code"
-	}
+	git := fakeGitCommand(2, "generic exit code")
 
-	version, err := getGitSemverTag()
+	version, err := describeVersion(git)
 
 	assert.NotNil(suite.T(), err)
 	assert.Equal(suite.T(), "", version)
 }
+
+// Get git with every command succeeding and FETCH_HEAD with content in dir
+func fakeGitFetch(dir string, fetch_head string) *gitCommand {
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "FETCH_HEAD"), []byte(fetch_head), 0644)
+	return fakeGitCommand(0, "")
+}
+
+func (suite *GitTestSuite) TestGitFetch() {
+	for _, fetch_head := range []string{
+		"bc47be35619cfa9d03a9478c3680912fce00f2ab\t\ttag 'v1.0.0' of https://github.com/example/wrench-templates\n",
+		"bc47be35619cfa9d03a9478c3680912fce00f2ab\t\t'bc47be35619cfa9d03a9478c3680912fce00f2ab' of https://github.com/example/wrench-templates\n",
+	} {
+		dir := suite.T().TempDir()
+
+		err := fakeGitFetch(dir, fetch_head).Fetch("https://github.com/example/wrench-templates", "v1.0.0", dir)
+
+		assert.Nil(suite.T(), err, fetch_head)
+	}
+}
+
+func (suite *GitTestSuite) TestGitFetchBranch() {
+	dir := suite.T().TempDir()
+	git := fakeGitFetch(dir, "bc47be35619cfa9d03a9478c3680912fce00f2ab\t\tbranch 'main' of https://github.com/example/wrench-templates\n")
+
+	err := git.Fetch("https://github.com/example/wrench-templates", "main", dir)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Ref main of https://github.com/example/wrench-templates is a branch, pin include to a tag or commit", err.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file included by another config file, either a local path
+// relative to the including file or a path in a git repository pinned to
+// a ref
+type Include struct {
+	Git  string `yaml:"Git,omitempty"`
+	Ref  string `yaml:"Ref,omitempty"`
+	Path string `yaml:"Path"`
+}
+
+// Include is either a path or a map
+func (i *Include) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		i.Path = path
+		return nil
+	}
+
+	type plain Include
+	return unmarshal((*plain)(i))
+}
+
+func parseIncludes(content string) ([]Include, error) {
+	var uconfig struct {
+		Include []Include `yaml:"Include,omitempty"`
+	}
+
+	if err := yaml.Unmarshal([]byte(content), &uconfig); err != nil {
+		return nil, errors.New("Unable to unmarshall Include as list")
+	}
+
+	for _, include := range uconfig.Include {
+		if include.Path == "" {
+			return nil, errors.New("Path empty for include")
+		}
+		if include.Git != "" && include.Ref == "" {
+			return nil, errors.New(fmt.Sprintf("Ref must be set to pin include %s from %s", include.Path, include.Git))
+		}
+	}
+
+	return uconfig.Include, nil
+}
+
+// Get cache directory, $XDG_CACHE_HOME/wrench with fallback to
+// ~/.cache/wrench
+func (r *Resolved) getCacheDir() string {
+	dir := r.environ["XDG_CACHE_HOME"]
+	if dir == "" {
+		home := r.environ["HOME"]
+		if home == "" {
+			return filepath.Join(os.TempDir(), "wrench")
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "wrench")
+}
+
+// Git repository a config file is included from, nil for local files
+type includeSource struct {
+	Git string
+
+	// Checkout of repository in cache
+	Dir string
+}
+
+// Check if path is inside source repository
+func (s *includeSource) contains(path string) bool {
+	rel, err := filepath.Rel(s.Dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Get local path of included file relative to file including it and the
+// git repository it is from. Git repositories are fetched once per ref and
+// reused from cache, so refs must be tags or commits. Paths in git
+// repositories must not leave them, also for local includes of files from
+// git repositories.
+func (r *Resolved) resolveInclude(include Include, from string, source *includeSource) (string, *includeSource, error) {
+	if include.Git == "" {
+		path := include.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(from), include.Path)
+		}
+		if source != nil && (filepath.IsAbs(include.Path) || !source.contains(path)) {
+			return "", nil, errors.New(fmt.Sprintf("Include %s is outside of %s", include.Path, source.Git))
+		}
+		return path, source, nil
+	}
+
+	hash := sha256.Sum256([]byte(include.Git + "\n" + include.Ref))
+	dir := filepath.Join(r.getCacheDir(), "includes", fmt.Sprintf("%x", hash[:8]))
+	source = &includeSource{Git: include.Git, Dir: dir}
+
+	path := filepath.Join(dir, include.Path)
+	if !source.contains(path) {
+		return "", nil, errors.New(fmt.Sprintf("Include %s is outside of %s", include.Path, include.Git))
+	}
+
+	if !utils.FileExists(dir) {
+		tmp := dir + ".tmp"
+		os.RemoveAll(tmp)
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", nil, err
+		}
+		if err := r.git.Fetch(include.Git, include.Ref, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return "", nil, err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return path, source, nil
+}
+
+// Check that config from git repository only has values that stay inside
+// containers. Files from git can't run commands on the host, mount host
+// paths, use secrets, pass host environment variables or change how and
+// where images are built.
+func checkGitIncludeConfig(config Config) error {
+	var messages []string
+
+	for key, set := range map[string]bool{
+		"Build":    config.Build != nil,
+		"Cache":    config.Cache != nil,
+		"Secrets":  len(config.Secrets) > 0,
+		"Projects": len(config.Projects) > 0,
+	} {
+		if set {
+			messages = append(messages, fmt.Sprintf("%s not allowed in git include", key))
+		}
+	}
+
+	for name, run := range config.Run {
+		if len(run.Volumes) > 0 {
+			messages = append(messages, fmt.Sprintf("Run %s: Volumes not allowed in git include", name))
+		}
+		if len(run.Secrets) > 0 {
+			messages = append(messages, fmt.Sprintf("Run %s: Secrets not allowed in git include", name))
+		}
+		for _, e := range run.Env {
+			if !strings.Contains(e, "=") {
+				messages = append(messages, fmt.Sprintf("Run %s: Env %s without value not allowed in git include", name, e))
+			}
+		}
+		for service_name, service := range run.Services {
+			for _, e := range service.Env {
+				if !strings.Contains(e, "=") {
+					messages = append(messages, fmt.Sprintf("Run %s: Service %s: Env %s without value not allowed in git include", name, service_name, e))
+				}
+			}
+		}
+	}
+
+	if len(messages) > 0 {
+		sort.Strings(messages)
+		return errors.New(strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
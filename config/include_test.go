@@ -0,0 +1,223 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type IncludeTestSuite struct {
+	suite.Suite
+	dir string
+	git *fakeGit
+}
+
+func TestIncludeTestSuite(t *testing.T) {
+	suite.Run(t, new(IncludeTestSuite))
+}
+
+func (suite *IncludeTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.git = &fakeGit{}
+
+	os.MkdirAll(filepath.Join(suite.dir, "project", "ci"), 0755)
+}
+
+// Get project with global config in home and cache in cache
+func (suite *IncludeTestSuite) resolved() *Resolved {
+	r := newTestResolved(filepath.Join(suite.dir, "project"), Config{}, suite.git,
+		"XDG_CONFIG_HOME="+filepath.Join(suite.dir, "home"),
+		"XDG_CACHE_HOME="+filepath.Join(suite.dir, "cache"))
+	r.config_file = filepath.Join(suite.dir, "project", "wrench.yml")
+	return r
+}
+
+func (suite *IncludeTestSuite) writeFile(path string, content string) {
+	ioutil.WriteFile(filepath.Join(suite.dir, path), []byte(content), 0644)
+}
+
+func (suite *IncludeTestSuite) TestParseIncludes() {
+	content := "Include:\n" +
+		"  - ci/python.yml\n" +
+		"  - Git: https://github.com/example/wrench-templates.git\n" +
+		"    Ref: v1.0.0\n" +
+		"    Path: python.yml\n"
+
+	includes, err := parseIncludes(content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Include{
+		{Path: "ci/python.yml"},
+		{Git: "https://github.com/example/wrench-templates.git", Ref: "v1.0.0", Path: "python.yml"},
+	}, includes)
+}
+
+func (suite *IncludeTestSuite) TestParseIncludesGitWithoutRef() {
+	content := "Include:\n" +
+		"  - Git: https://github.com/example/wrench-templates.git\n" +
+		"    Path: python.yml\n"
+
+	_, err := parseIncludes(content)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Ref must be set to pin include python.yml from https://github.com/example/wrench-templates.git", err.Error())
+	}
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeLocal() {
+	suite.writeFile("project/ci/python.yml", "Run:\n  lint: flake8\n  unit:\n    Cmd: pytest\n    Env:\n      - A=1\n")
+	suite.writeFile("project/wrench.yml", "Include:\n  - ci/python.yml\nRun:\n  unit:\n    Env:\n      - B=2\n")
+
+	r := suite.resolved()
+	c, err := r.loadConfigFile()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]Run{
+		"lint": {Cmd: "flake8"},
+		"unit": {Cmd: "pytest", Env: []string{"A=1", "B=2"}},
+	}, c.Run)
+	assert.Equal(suite.T(), "ci/python.yml:2", r.origins["Run.lint"])
+	assert.Equal(suite.T(), "wrench.yml:4", r.origins["Run.unit"])
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeGit() {
+	var fetched []string
+	suite.git.fetch = func(repo string, ref string, dir string) error {
+		fetched = append(fetched, repo+"@"+ref)
+		os.MkdirAll(dir, 0755)
+		return ioutil.WriteFile(filepath.Join(dir, "python.yml"), []byte("Run:\n  lint: flake8\n"), 0644)
+	}
+	suite.writeFile("project/wrench.yml", "Include:\n"+
+		"  - Git: https://github.com/example/wrench-templates.git\n"+
+		"    Ref: v1.0.0\n"+
+		"    Path: python.yml\n")
+
+	c, err := suite.resolved().loadConfigFile()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]Run{"lint": {Cmd: "flake8"}}, c.Run)
+
+	// Second load is served from cache
+	_, err = suite.resolved().loadConfigFile()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"https://github.com/example/wrench-templates.git@v1.0.0"}, fetched)
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeNotFound() {
+	suite.writeFile("project/wrench.yml", "Include:\n  - ci/missing.yml\n")
+
+	_, err := suite.resolved().loadConfigFile()
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "wrench.yml: Included file ci/missing.yml not found", err.Error())
+	}
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeCycle() {
+	suite.writeFile("project/ci/a.yml", "Include:\n  - b.yml\n")
+	suite.writeFile("project/ci/b.yml", "Include:\n  - a.yml\n")
+	suite.writeFile("project/wrench.yml", "Include:\n  - ci/a.yml\n")
+
+	_, err := suite.resolved().loadConfigFile()
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Include cycle: ci/a.yml -> ci/b.yml -> ci/a.yml", err.Error())
+	}
+}
+
+func (suite *IncludeTestSuite) TestResolveIncludeGitOutsideRepository() {
+	suite.git.fetch = func(repo string, ref string, dir string) error {
+		return os.MkdirAll(dir, 0755)
+	}
+
+	for _, path := range []string{"../../wrench.yml", "ci/../../secret.yml", ".."} {
+		_, _, err := suite.resolved().resolveInclude(Include{Git: "https://github.com/example/wrench-templates.git", Ref: "v1.0.0", Path: path}, "wrench.yml", nil)
+
+		if assert.NotNil(suite.T(), err, path) {
+			assert.Equal(suite.T(), "Include "+path+" is outside of https://github.com/example/wrench-templates.git", err.Error())
+		}
+	}
+
+	path, _, err := suite.resolved().resolveInclude(Include{Git: "https://github.com/example/wrench-templates.git", Ref: "v1.0.0", Path: "ci/../python.yml"}, "wrench.yml", nil)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "python.yml", filepath.Base(path))
+}
+
+// Make git include fetch files with content into repository
+func (suite *IncludeTestSuite) fetchFiles(files map[string]string) {
+	suite.git.fetch = func(repo string, ref string, dir string) error {
+		for path, content := range files {
+			os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755)
+			if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	suite.writeFile("project/wrench.yml", "Include:\n"+
+		"  - Git: https://github.com/example/wrench-templates.git\n"+
+		"    Ref: v1.0.0\n"+
+		"    Path: ci/python.yml\n")
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeGitNotAllowed() {
+	suite.fetchFiles(map[string]string{"ci/python.yml": "Secrets:\n" +
+		"  TOKEN:\n" +
+		"    Command: curl https://example.com\n" +
+		"Run:\n" +
+		"  unit:\n" +
+		"    Cmd: pytest\n" +
+		"    Env:\n" +
+		"      - HOME\n" +
+		"      - CI=true\n" +
+		"    Volumes:\n" +
+		"      - /:/host\n"})
+
+	_, err := suite.resolved().loadConfigFile()
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Contains(suite.T(), err.Error(), "ci/python.yml: Run unit: Env HOME without value not allowed in git include\n"+
+			"Run unit: Volumes not allowed in git include\n"+
+			"Secrets not allowed in git include")
+	}
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeGitTemplateFuncs() {
+	for content, message := range map[string]string{
+		"Run:\n  unit: echo {{ env \"HOME\" }}\n":         "env not allowed in git include",
+		"Run:\n  unit: echo {{ file \"/etc/passwd\" }}\n": "file not allowed in git include",
+	} {
+		suite.fetchFiles(map[string]string{"ci/python.yml": content})
+		os.RemoveAll(filepath.Join(suite.dir, "cache"))
+
+		_, err := suite.resolved().loadConfigFile()
+
+		if assert.NotNil(suite.T(), err, content) {
+			assert.Contains(suite.T(), err.Error(), message)
+		}
+	}
+
+	suite.fetchFiles(map[string]string{"ci/python.yml": "Run:\n  unit: echo {{ index .Environ \"HOME\" | default \"none\" }}\n"})
+	os.RemoveAll(filepath.Join(suite.dir, "cache"))
+
+	c, err := suite.resolved().loadConfigFile()
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "echo none", c.Run["unit"].Cmd)
+	}
+}
+
+func (suite *IncludeTestSuite) TestLoadConfigFileIncludeGitLocalOutsideRepository() {
+	suite.fetchFiles(map[string]string{
+		"ci/python.yml": "Include:\n  - common.yml\n  - ../../../../project/secrets.yml\n",
+		"ci/common.yml": "Run:\n  lint: flake8\n",
+	})
+
+	_, err := suite.resolved().loadConfigFile()
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Include ../../../../project/secrets.yml is outside of https://github.com/example/wrench-templates.git", err.Error())
+	}
+}
@@ -16,31 +16,16 @@ func TestInitialVersionTestSuite(t *testing.T) {
 	suite.Run(t, new(InitialVersionTestSuite))
 }
 
-func (suite *InitialVersionTestSuite) TearDownTest() {
-	getGitShortSha = mocked_functions["getGitShortSha"].(func() (string, error))
-	getGitCommitCount = mocked_functions["getGitCommitCount"].(func() (int, error))
-}
-
 func (suite *InitialVersionTestSuite) TestInitialVersion() {
-	getGitShortSha = func() (string, error) {
-		return "aoeu123", nil
-	}
-	getGitCommitCount = func() (int, error) {
-		return 1, nil
-	}
+	r := newTestResolved("/foobar", Config{}, &fakeGit{short_sha: "aoeu123", commit_count: 1})
 
-	assert.Equal(suite.T(), "v0.0.0-1-gaoeu123", generateInitialVersion())
+	assert.Equal(suite.T(), "v0.0.0-1-gaoeu123", mustDetect(suite.T(), r.generateInitialVersion))
 }
 
 func (suite *InitialVersionTestSuite) TestInitialVersionFoobar() {
-	getGitShortSha = func() (string, error) {
-		return "foobar", nil
-	}
 	for _, i := range []int{0, 1, 5, 10, 50, 99, 100, 1000, 10000} {
-		getGitCommitCount = func() (int, error) {
-			return i, nil
-		}
+		r := newTestResolved("/foobar", Config{}, &fakeGit{short_sha: "foobar", commit_count: i})
 
-		assert.Equal(suite.T(), fmt.Sprintf("v0.0.0-%d-gfoobar", i), generateInitialVersion())
+		assert.Equal(suite.T(), fmt.Sprintf("v0.0.0-%d-gfoobar", i), mustDetect(suite.T(), r.generateInitialVersion))
 	}
 }
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const localConfigFileName = "wrench.local.yml"
+
+// Environment variables overriding Project values
+var envOverrides = []struct {
+	Name  string
+	Key   string
+	Field func(c *Config) *string
+}{
+	{"WRENCH_PROJECT_ORGANIZATION", "Project.Organization", func(c *Config) *string { return &c.Project.Organization }},
+	{"WRENCH_PROJECT_NAME", "Project.Name", func(c *Config) *string { return &c.Project.Name }},
+	{"WRENCH_PROJECT_VERSION", "Project.Version", func(c *Config) *string { return &c.Project.Version }},
+	{"WRENCH_PROJECT_IMAGE", "Project.Image", func(c *Config) *string { return &c.Project.Image }},
+}
+
+// Get global user config file, $XDG_CONFIG_HOME/wrench/config.yml with
+// fallback to ~/.config/wrench/config.yml
+func (r *Resolved) getGlobalConfigFile() string {
+	dir := r.environ["XDG_CONFIG_HOME"]
+	if dir == "" {
+		home := r.environ["HOME"]
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "wrench", "config.yml")
+}
+
+// Get config files in order of precedence, later files override earlier.
+// Files that don't exist are skipped when loading.
+func (r *Resolved) getConfigFiles() []string {
+	files := []string{r.getGlobalConfigFile()}
+
+	if r.monorepo_config_file != "" {
+		files = append(files, r.monorepo_config_file)
+	}
+
+	if r.config_file != "" {
+		files = append(files, r.config_file, filepath.Join(filepath.Dir(r.config_file), localConfigFileName))
+	} else {
+		files = append(files, filepath.Join(r.dir, localConfigFileName))
+	}
+
+	return files
+}
+
+// Check if file is only meant to override parts of the project config
+func (r *Resolved) isConfigOverlay(file string) bool {
+	if filepath.Base(file) == localConfigFileName {
+		return true
+	}
+	if abs, err := filepath.Abs(file); err == nil && abs == r.getGlobalConfigFile() {
+		return true
+	}
+	return false
+}
+
+// Merge override into base. Project values and run item fields set in
+// override replace the ones in base, Env is merged by variable name and
+// Services, Params and Secrets are merged by name.
+func mergeConfig(base Config, override Config) Config {
+	merged := Config{Project: base.Project}
+
+	mergeString(&merged.Project.Organization, override.Project.Organization)
+	mergeString(&merged.Project.Name, override.Project.Name)
+	mergeString(&merged.Project.Version, override.Project.Version)
+	mergeString(&merged.Project.Image, override.Project.Image)
+
+	merged.Versioning = base.Versioning
+	if override.Versioning != nil {
+		versioning := Versioning{}
+		if base.Versioning != nil {
+			versioning = *base.Versioning
+		}
+		mergeString(&versioning.Strategy, override.Versioning.Strategy)
+		if override.Versioning.MainBranches != nil {
+			versioning.MainBranches = override.Versioning.MainBranches
+		}
+		if override.Versioning.TagPrefix != nil {
+			versioning.TagPrefix = override.Versioning.TagPrefix
+		}
+		mergeString(&versioning.TagPattern, override.Versioning.TagPattern)
+		merged.Versioning = &versioning
+	}
+
+	// Tag templates are replaced as a whole
+	merged.TagTemplates = base.TagTemplates
+	if override.TagTemplates != nil {
+		merged.TagTemplates = override.TagTemplates
+	}
+
+	merged.Build = base.Build
+	if override.Build != nil {
+		build := Build{}
+		if base.Build != nil {
+			build = *base.Build
+		}
+		mergeString(&build.Backend, override.Build.Backend)
+		mergeString(&build.Builder, override.Build.Builder)
+		mergeString(&build.Endpoint, override.Build.Endpoint)
+		if override.Build.Ssh != nil {
+			build.Ssh = override.Build.Ssh
+		}
+		merged.Build = &build
+	}
+
+	merged.Cache = base.Cache
+	if override.Cache != nil {
+		cache := Cache{}
+		if base.Cache != nil {
+			cache = *base.Cache
+		}
+		if override.Cache.From != nil {
+			cache.From = override.Cache.From
+		}
+		if override.Cache.To != nil {
+			cache.To = override.Cache.To
+		}
+		if override.Cache.Previous != nil {
+			cache.Previous = override.Cache.Previous
+		}
+		merged.Cache = &cache
+	}
+
+	// Projects are replaced as a whole
+	merged.Projects = base.Projects
+	if override.Projects != nil {
+		merged.Projects = override.Projects
+	}
+
+	if len(base.Run) > 0 || len(override.Run) > 0 {
+		merged.Run = make(map[string]Run)
+		for name, run := range base.Run {
+			merged.Run[name] = run
+		}
+		for name, run := range override.Run {
+			if base_run, ok := merged.Run[name]; ok {
+				run = mergeRun(base_run, run)
+			}
+			merged.Run[name] = run
+		}
+	}
+
+	if len(base.Secrets) > 0 || len(override.Secrets) > 0 {
+		merged.Secrets = make(map[string]Secret)
+		for name, secret := range base.Secrets {
+			merged.Secrets[name] = secret
+		}
+		for name, secret := range override.Secrets {
+			merged.Secrets[name] = secret
+		}
+	}
+
+	return merged
+}
+
+func mergeRun(base Run, override Run) Run {
+	merged := base
+
+	mergeString(&merged.Cmd, override.Cmd)
+	mergeString(&merged.Image, override.Image)
+	mergeString(&merged.Entrypoint, override.Entrypoint)
+	mergeString(&merged.Workdir, override.Workdir)
+	mergeString(&merged.User, override.User)
+
+	merged.Env = mergeEnv(base.Env, override.Env)
+
+	// Lists are replaced as a whole
+	if override.Volumes != nil {
+		merged.Volumes = override.Volumes
+	}
+	if override.DependsOn != nil {
+		merged.DependsOn = override.DependsOn
+	}
+	if override.Secrets != nil {
+		merged.Secrets = override.Secrets
+	}
+
+	if len(override.Services) > 0 {
+		merged.Services = make(map[string]Service)
+		for name, service := range base.Services {
+			merged.Services[name] = service
+		}
+		for name, service := range override.Services {
+			merged.Services[name] = service
+		}
+	}
+
+	if len(override.Params) > 0 {
+		merged.Params = make(map[string]*string)
+		for name, value := range base.Params {
+			merged.Params[name] = value
+		}
+		for name, value := range override.Params {
+			merged.Params[name] = value
+		}
+	}
+
+	return merged
+}
+
+func mergeString(base *string, override string) {
+	if override != "" {
+		*base = override
+	}
+}
+
+// Merge env lists by variable name, keeping order of base
+func mergeEnv(base []string, override []string) []string {
+	if len(override) == 0 {
+		return base
+	}
+
+	var merged []string
+	index := make(map[string]int)
+
+	for _, env := range append(append([]string{}, base...), override...) {
+		name := strings.SplitN(env, "=", 2)[0]
+		if i, ok := index[name]; ok {
+			merged[i] = env
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, env)
+	}
+
+	return merged
+}
+
+// Override Project values with WRENCH_* environment variables
+func (r *Resolved) applyEnvOverrides(config *Config) {
+	for _, override := range envOverrides {
+		if value, ok := r.environ[override.Name]; ok && value != "" {
+			*override.Field(config) = value
+			r.setOrigin(override.Key, fmt.Sprintf("env %s", override.Name))
+		}
+	}
+}
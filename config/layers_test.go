@@ -0,0 +1,130 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LayersTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestLayersTestSuite(t *testing.T) {
+	suite.Run(t, new(LayersTestSuite))
+}
+
+func (suite *LayersTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+
+	os.MkdirAll(filepath.Join(suite.dir, "home", "wrench"), 0755)
+	os.MkdirAll(filepath.Join(suite.dir, "project"), 0755)
+}
+
+// Get project with global config in home and environment variables
+func (suite *LayersTestSuite) resolved(environ ...string) *Resolved {
+	environ = append(environ, "XDG_CONFIG_HOME="+filepath.Join(suite.dir, "home"))
+	r := newTestResolved(filepath.Join(suite.dir, "project"), Config{}, nil, environ...)
+	r.config_file = filepath.Join(suite.dir, "project", "wrench.yml")
+	return r
+}
+
+func (suite *LayersTestSuite) writeFile(path string, content string) {
+	ioutil.WriteFile(filepath.Join(suite.dir, path), []byte(content), 0644)
+}
+
+func (suite *LayersTestSuite) TestMergeConfigProject() {
+	base := Config{Project: Project{Organization: "acme", Name: "foobar"}}
+	override := Config{Project: Project{Name: "barfoo", Version: "v1.0.0"}}
+
+	merged := mergeConfig(base, override)
+
+	assert.Equal(suite.T(), Project{Organization: "acme", Name: "barfoo", Version: "v1.0.0"}, merged.Project)
+}
+
+func (suite *LayersTestSuite) TestMergeConfigRun() {
+	base := Config{Run: map[string]Run{
+		"unit": {Cmd: "pytest", Env: []string{"A=1", "B=2"}, Volumes: []string{"./a:/a"}},
+		"lint": {Cmd: "flake8"},
+	}}
+	override := Config{Run: map[string]Run{
+		"unit": {Env: []string{"B=3", "C=4"}, Volumes: []string{"./b:/b"}},
+		"docs": {Cmd: "mkdocs build"},
+	}}
+
+	merged := mergeConfig(base, override)
+
+	assert.Equal(suite.T(), map[string]Run{
+		"unit": {Cmd: "pytest", Env: []string{"A=1", "B=3", "C=4"}, Volumes: []string{"./b:/b"}},
+		"lint": {Cmd: "flake8"},
+		"docs": {Cmd: "mkdocs build"},
+	}, merged.Run)
+}
+
+func (suite *LayersTestSuite) TestMergeConfigRunParams() {
+	one := "1"
+	two := "2"
+	base := Config{Run: map[string]Run{
+		"unit": {Cmd: "pytest", Params: map[string]*string{"A": &one, "B": nil}},
+	}}
+	override := Config{Run: map[string]Run{
+		"unit": {Params: map[string]*string{"B": &two}},
+	}}
+
+	merged := mergeConfig(base, override)
+
+	assert.Equal(suite.T(), map[string]*string{"A": &one, "B": &two}, merged.Run["unit"].Params)
+	assert.Nil(suite.T(), base.Run["unit"].Params["B"])
+}
+
+func (suite *LayersTestSuite) TestLoadConfigFileLayers() {
+	suite.writeFile("home/wrench/config.yml", "Project:\n  Organization: acme\n")
+	suite.writeFile("project/wrench.yml", "Project:\n  Name: foobar\nRun:\n  unit:\n    Cmd: pytest\n    Env:\n      - DB=postgres\n")
+	suite.writeFile("project/wrench.local.yml", "Run:\n  unit:\n    Env:\n      - DEBUG=1\n")
+
+	r := suite.resolved()
+	c, err := r.loadConfigFile()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), Project{Organization: "acme", Name: "foobar"}, c.Project)
+	assert.Equal(suite.T(), Run{Cmd: "pytest", Env: []string{"DB=postgres", "DEBUG=1"}}, c.Run["unit"])
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "home", "wrench", "config.yml")+":2", r.origins["Project.Organization"])
+	assert.Equal(suite.T(), "wrench.yml:2", r.origins["Project.Name"])
+	assert.Equal(suite.T(), "wrench.local.yml:2", r.origins["Run.unit"])
+}
+
+func (suite *LayersTestSuite) TestLoadConfigFileEnv() {
+	suite.writeFile("project/wrench.yml", "Project:\n  Name: foobar\n  Version: v1.0.0\n")
+	r := suite.resolved("WRENCH_PROJECT_VERSION=v2.0.0", "WRENCH_PROJECT_NAME=")
+
+	c, err := r.loadConfigFile()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), Project{Name: "foobar", Version: "v2.0.0"}, c.Project)
+	assert.Equal(suite.T(), "env WRENCH_PROJECT_VERSION", r.origins["Project.Version"])
+	assert.Equal(suite.T(), "wrench.yml:2", r.origins["Project.Name"])
+}
+
+func (suite *LayersTestSuite) TestLoadConfigFileValidatesMerged() {
+	suite.writeFile("project/wrench.yml", "Run:\n  unit: pytest\n")
+	suite.writeFile("project/wrench.local.yml", "Run:\n  all:\n    DependsOn:\n      - lint\n")
+
+	_, err := suite.resolved().loadConfigFile()
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown dependency lint for run item all", err.Error())
+	}
+}
+
+func (suite *LayersTestSuite) TestIsConfigOverlay() {
+	r := suite.resolved()
+
+	assert.True(suite.T(), r.isConfigOverlay("wrench.local.yml"))
+	assert.True(suite.T(), r.isConfigOverlay(filepath.Join(suite.dir, "home", "wrench", "config.yml")))
+	assert.False(suite.T(), r.isConfigOverlay("wrench.yml"))
+}
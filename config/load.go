@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+)
+
+// Options for loading a project, dependencies not set use the git
+// command, the hostname command and the process environment
+type Options struct {
+	// Config file instead of discovered wrench.yml, relative to the
+	// directory project is loaded from
+	ConfigFile string
+
+	Git  Git
+	Host Host
+	Env  Env
+}
+
+// Host wrench runs on, organization is detected from its hostname
+type Host interface {
+	Hostname() (string, error)
+}
+
+// Environment of wrench, used for env in templates and WRENCH_*
+// overrides. Values are KEY=value like os.Environ.
+type Env interface {
+	Environ() []string
+}
+
+type osHost struct{}
+
+func (osHost) Hostname() (string, error) {
+	out, err := exec.Command("hostname", "-f").Output()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("hostname exited with %d", utils.GetCommandExitCode(err)))
+	}
+	return string(out), nil
+}
+
+type osEnv struct{}
+
+func (osEnv) Environ() []string {
+	return os.Environ()
+}
+
+// Config of a project with values not set in config detected. Resolved
+// config is not changed after it is loaded, load it again to pick up new
+// tags or config changes.
+type Resolved struct {
+	config Config
+
+	// Project directory
+	dir string
+
+	// Path to config file in use, empty if project has none
+	config_file string
+
+	// Path to top-level config file of monorepo, empty if project is not
+	// part of one
+	monorepo_config_file string
+
+	// Origin of every config value, file and line or detection rule
+	origins map[string]string
+
+	git      Git
+	git_info GitInfo
+	host     Host
+	environ  map[string]string
+}
+
+// Get resolved context for dir with dependencies of options, config is
+// empty until loaded
+func newResolved(dir string, options Options) *Resolved {
+	r := &Resolved{
+		dir:     dir,
+		origins: map[string]string{},
+		git:     options.Git,
+		host:    options.Host,
+		environ: map[string]string{},
+	}
+
+	if r.git == nil {
+		r.git = newGitCommand(dir)
+	}
+	if r.host == nil {
+		r.host = osHost{}
+	}
+
+	env := options.Env
+	if env == nil {
+		env = osEnv{}
+	}
+	for _, item := range env.Environ() {
+		splits := strings.Split(item, "=")
+		r.environ[splits[0]] = strings.Join(splits[1:], "=")
+	}
+
+	r.git_info = readGitInfo(r.git)
+
+	return r
+}
+
+// Load config of project discovered from dir, see wrench.yml discovery in
+// README. Values not set in config are detected.
+func Load(dir string, options Options) (*Resolved, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newResolved(start, options)
+
+	if err := r.discover(start, options.ConfigFile); err != nil {
+		return nil, err
+	}
+
+	c, err := r.loadConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	r.config = c
+
+	if err := r.resolveProject(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Find config file of project discovered from dir without loading it,
+// wrench.yml in the project directory if project has none
+func Discover(dir string, options Options) (string, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	r := newResolved(start, options)
+	if err := r.discover(start, options.ConfigFile); err != nil {
+		return "", err
+	}
+
+	return r.GetConfigFile(), nil
+}
+
+// Project directory
+func (r *Resolved) Dir() string {
+	return r.dir
+}
+
+// Get the config file of the project, wrench.yml in project directory if
+// project has none
+func (r *Resolved) GetConfigFile() string {
+	if r.config_file == "" {
+		return filepath.Join(r.dir, configFileName)
+	}
+	return r.config_file
+}
+
+// Get git information of project, empty if not a git repository
+func (r *Resolved) GetGitInfo() GitInfo {
+	return r.git_info
+}
+
+// Get git repository of project
+func (r *Resolved) Git() Git {
+	return r.git
+}
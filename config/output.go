@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+)
+
+// Formats for config output
+var OutputFormats = []string{"yaml", "json", "env", "export", "github"}
+
+type envValue struct {
+	Name  string
+	Value string
+}
+
+// Prefix of exported project values. It differs from the WRENCH_PROJECT_*
+// overrides, so exported values are not loaded back as overrides.
+const outputEnvPrefix = "WRENCH_CONFIG_"
+
+// Get project values as WRENCH_CONFIG_* environment variables
+func (r *Resolved) getProjectEnv() ([]envValue, error) {
+	ref, err := r.GetProjectImageReference()
+	if err != nil {
+		return nil, err
+	}
+
+	return []envValue{
+		{outputEnvPrefix + "PROJECT_ORGANIZATION", r.GetProjectOrganization()},
+		{outputEnvPrefix + "PROJECT_NAME", r.GetProjectName()},
+		{outputEnvPrefix + "PROJECT_VERSION", r.GetProjectVersion()},
+		{outputEnvPrefix + "PROJECT_IMAGE", ref.String()},
+		{outputEnvPrefix + "PROJECT_BUILDER_IMAGE", ref.WithTagSuffix("-builder").String()},
+		{outputEnvPrefix + "PROJECT_TEST_IMAGE", ref.WithTagSuffix("-test").String()},
+	}, nil
+}
+
+// Get config in output format
+func (r *Resolved) OutputConfig(output string) (string, error) {
+	var project_env []envValue
+	if output == "env" || output == "export" || output == "github" {
+		var err error
+		if project_env, err = r.getProjectEnv(); err != nil {
+			return "", err
+		}
+	}
+
+	switch output {
+	case "yaml":
+		return r.FormatConfig("")
+	case "json":
+		d, err := json.MarshalIndent(&r.config, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(d), nil
+	case "env":
+		var lines []string
+		for _, env := range project_env {
+			lines = append(lines, fmt.Sprintf("%s=%s", env.Name, env.Value))
+		}
+		return strings.Join(lines, "\n"), nil
+	case "export":
+		var lines []string
+		for _, env := range project_env {
+			lines = append(lines, fmt.Sprintf("export %s=%s", env.Name, utils.ShellQuote(env.Value)))
+		}
+		return strings.Join(lines, "\n"), nil
+	case "github":
+		// Outputs are named without prefix, steps.wrench.outputs.project_name
+		var lines []string
+		for _, env := range project_env {
+			name := strings.ToLower(strings.TrimPrefix(env.Name, outputEnvPrefix))
+			if strings.Contains(env.Value, "\n") {
+				lines = append(lines, fmt.Sprintf("%s<<WRENCH_EOF\n%s\nWRENCH_EOF", name, env.Value))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s=%s", name, env.Value))
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("Unknown output format %s, must be one of %s", output, strings.Join(OutputFormats, ", ")))
+}
+
+// Append outputs to file in $GITHUB_OUTPUT, or print them if not set
+func WriteGithubOutput(content string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		fmt.Println(content)
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, content)
+	return err
+}
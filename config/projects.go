@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomologic/wrench/dockerfile"
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// Project in a monorepo, listed in Projects of the top-level wrench.yml
+type SubProject struct {
+	// Directory of project relative to the top-level wrench.yml
+	Path string `yaml:"Path" json:"Path"`
+
+	// Paths of projects that must be built first, projects with an image
+	// used in FROM of the Dockerfiles are detected automatically
+	DependsOn []string `yaml:"DependsOn,omitempty" json:"DependsOn,omitempty"`
+}
+
+// Project is either a path or a map
+func (p *SubProject) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		p.Path = path
+		return nil
+	}
+
+	type plain SubProject
+	return unmarshal((*plain)(p))
+}
+
+// Check if project is the top-level project of a monorepo
+func (r *Resolved) IsMonorepo() bool {
+	return len(r.config.Projects) > 0
+}
+
+// Read Projects of config file, returns nil if file can't be parsed
+func (r *Resolved) readSubProjects(file string) []SubProject {
+	content, err := getConfigContent(file)
+	if err != nil || content == "" {
+		return nil
+	}
+
+	rendered, err := r.render(content, Project{})
+	if err != nil {
+		return nil
+	}
+
+	var uconfig struct {
+		Projects []SubProject `yaml:"Projects,omitempty"`
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &uconfig); err != nil {
+		return nil
+	}
+
+	return uconfig.Projects
+}
+
+// Find monorepo project dir is part of by walking up to the nearest
+// wrench.yml listing a project containing dir, stopping at the git root.
+// Returns project directory and top-level config file, both empty if dir is
+// not in a monorepo.
+func (r *Resolved) findMonorepoProject(dir string) (string, string) {
+	for current := dir; ; current = filepath.Dir(current) {
+		path := filepath.Join(current, configFileName)
+
+		if utils.FileExists(path) {
+			for _, project := range r.readSubProjects(path) {
+				project_dir := filepath.Join(current, project.Path)
+				if dir == project_dir || strings.HasPrefix(dir, project_dir+string(filepath.Separator)) {
+					return project_dir, path
+				}
+			}
+		}
+
+		if utils.FileExists(filepath.Join(current, ".git")) {
+			break
+		}
+
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+
+	return "", ""
+}
+
+// Remove values from top-level config of monorepo not shared by projects
+func getMonorepoDefaults(config Config) Config {
+	config.Project.Name = ""
+	config.Project.Version = ""
+	config.Project.Image = ""
+	config.Projects = nil
+	return config
+}
+
+func validateProjects(projects []SubProject) error {
+	var paths []string
+	for _, project := range projects {
+		path := project.Path
+		if path == "" {
+			return errors.New("Path empty for project")
+		}
+		if filepath.IsAbs(path) || filepath.Clean(path) != path || path == "." ||
+			strings.HasPrefix(path, "..") {
+			return errors.New(fmt.Sprintf("Project path %s must be a clean relative path inside the monorepo", path))
+		}
+		if utils.StringInSlice(path, paths) {
+			return errors.New(fmt.Sprintf("Project %s listed more than once", path))
+		}
+		paths = append(paths, path)
+	}
+
+	for _, project := range projects {
+		for _, dep := range project.DependsOn {
+			if !utils.StringInSlice(dep, paths) {
+				return errors.New(fmt.Sprintf("Unknown dependency %s for project %s", dep, project.Path))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Get image names without tag used in FROM of Dockerfiles in dir
+func getDockerfileBaseImages(dir string) []string {
+	var images []string
+
+	for _, name := range []string{"Dockerfile", "Dockerfile.builder"} {
+		path := filepath.Join(dir, name)
+		if !utils.FileExists(path) {
+			continue
+		}
+
+		content, err := utils.GetFileContent(path)
+		if err != nil {
+			continue
+		}
+
+		d, err := dockerfile.Parse(content)
+		if err != nil {
+			continue
+		}
+
+		for _, instruction := range d.Instructions {
+			if instruction.Command != "FROM" {
+				continue
+			}
+
+			from, err := dockerfile.ParseFrom(instruction.Args)
+			if err != nil || from.Image == "" {
+				continue
+			}
+
+			if ref, err := image.Parse(from.Image); err == nil && !utils.StringInSlice(ref.Name, images) {
+				images = append(images, ref.Name)
+			}
+		}
+	}
+
+	return images
+}
+
+// Get dependencies of every project by path, DependsOn and projects with
+// an image used as base image. Images are image names without tag by
+// project path.
+func getProjectDependencies(base string, projects []SubProject, images map[string]string) (map[string][]string, error) {
+	by_image := make(map[string]string)
+	for _, project := range projects {
+		name, ok := images[project.Path]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Image of project %s unknown", project.Path))
+		}
+		by_image[name] = project.Path
+	}
+
+	deps := make(map[string][]string)
+	for _, project := range projects {
+		deps[project.Path] = append([]string{}, project.DependsOn...)
+
+		for _, name := range getDockerfileBaseImages(filepath.Join(base, project.Path)) {
+			if dep, ok := by_image[name]; ok && dep != project.Path && !utils.StringInSlice(dep, deps[project.Path]) {
+				deps[project.Path] = append(deps[project.Path], dep)
+			}
+		}
+	}
+
+	return deps, nil
+}
+
+// Sort projects so dependencies come before projects depending on them,
+// keeping the listed order otherwise
+func sortProjects(projects []SubProject, deps map[string][]string) ([]SubProject, error) {
+	by_path := make(map[string]SubProject)
+	for _, project := range projects {
+		by_path[project.Path] = project
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var sorted []SubProject
+
+	var visit func(path string, stack []string) error
+	visit = func(path string, stack []string) error {
+		stack = append(stack, path)
+
+		switch state[path] {
+		case visiting:
+			// Only report the part of the stack that is the cycle
+			for i, p := range stack {
+				if p == path {
+					stack = stack[i:]
+					break
+				}
+			}
+			return errors.New(fmt.Sprintf("Dependency cycle in projects: %s", strings.Join(stack, " -> ")))
+		case visited:
+			return nil
+		}
+
+		state[path] = visiting
+		for _, dep := range deps[path] {
+			if err := visit(dep, stack); err != nil {
+				return err
+			}
+		}
+		state[path] = visited
+		sorted = append(sorted, by_path[path])
+
+		return nil
+	}
+
+	for _, project := range projects {
+		if err := visit(project.Path, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// Check if files in project directory changed since git ref
+func (r *Resolved) HasChangesSince(ref string) (bool, error) {
+	files, err := r.git.ChangedFiles(ref)
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range files {
+		if file == r.monorepo_config_file || strings.HasPrefix(file, r.dir+string(filepath.Separator)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Get projects listed in monorepo config
+func (r *Resolved) GetSubProjects() []SubProject {
+	return r.config.Projects
+}
+
+// Get projects of monorepo in build order. Images are the image names
+// without tag of every project by path. With changed_since set only
+// projects with files changed since that git ref and projects depending on
+// them are returned. All projects are changed if the top-level config is.
+func (r *Resolved) GetProjects(changed_since string, images map[string]string) ([]SubProject, error) {
+	base := r.dir
+
+	for _, project := range r.config.Projects {
+		if info, err := os.Stat(filepath.Join(base, project.Path)); err != nil || !info.IsDir() {
+			return nil, errors.New(fmt.Sprintf("Project directory %s not found", project.Path))
+		}
+	}
+
+	deps, err := getProjectDependencies(base, r.config.Projects, images)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted, err := sortProjects(r.config.Projects, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed_since == "" {
+		return sorted, nil
+	}
+
+	files, err := r.git.ChangedFiles(changed_since)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for _, project := range sorted {
+		project_dir := filepath.Join(base, project.Path)
+		for _, file := range files {
+			if file == filepath.Join(base, configFileName) ||
+				strings.HasPrefix(file, project_dir+string(filepath.Separator)) {
+				changed[project.Path] = true
+				break
+			}
+		}
+
+		// Dependencies are sorted first, rebuild if a base image changed
+		for _, dep := range deps[project.Path] {
+			if changed[dep] {
+				changed[project.Path] = true
+			}
+		}
+	}
+
+	var filtered []SubProject
+	for _, project := range sorted {
+		if changed[project.Path] {
+			filtered = append(filtered, project)
+		}
+	}
+
+	return filtered, nil
+}
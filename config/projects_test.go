@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProjectsTestSuite struct {
+	suite.Suite
+	dir    string
+	git    *fakeGit
+	r      *Resolved
+	images map[string]string
+}
+
+func TestProjectsTestSuite(t *testing.T) {
+	suite.Run(t, new(ProjectsTestSuite))
+}
+
+func (suite *ProjectsTestSuite) SetupTest() {
+	dir := suite.T().TempDir()
+	suite.dir = dir
+
+	for _, path := range []string{"services/api/src", "services/web", "libs/base"} {
+		os.MkdirAll(filepath.Join(dir, path), 0755)
+	}
+	suite.writeFile("wrench.yml", "Project:\n  Organization: acme\nProjects:\n  - services/api\n  - Path: services/web\n    DependsOn: [services/api]\n  - libs/base\n")
+	suite.writeFile("libs/base/Dockerfile", "FROM alpine:3\n")
+	suite.writeFile("services/api/Dockerfile", "FROM --platform=linux/amd64 acme/base:latest AS base\n")
+	suite.writeFile("services/web/wrench.yml", "Project:\n  Name: webapp\n")
+
+	suite.images = map[string]string{
+		"services/api": "acme/api",
+		"services/web": "acme/web",
+		"libs/base":    "acme/base",
+	}
+	suite.git = &fakeGit{changed: []string{filepath.Join(dir, "libs", "base", "Dockerfile")}}
+
+	suite.r = newTestResolved(dir, Config{Projects: []SubProject{
+		{Path: "services/api"},
+		{Path: "services/web", DependsOn: []string{"services/api"}},
+		{Path: "libs/base"},
+	}}, suite.git)
+}
+
+func (suite *ProjectsTestSuite) writeFile(path string, content string) {
+	ioutil.WriteFile(filepath.Join(suite.dir, path), []byte(content), 0644)
+}
+
+func paths(projects []SubProject) []string {
+	var paths []string
+	for _, project := range projects {
+		paths = append(paths, project.Path)
+	}
+	return paths
+}
+
+func (suite *ProjectsTestSuite) TestParseProjects() {
+	c, err := unmarshallConfig("Projects:\n  - services/api\n  - Path: services/web\n    DependsOn: [services/api]\n")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []SubProject{
+		{Path: "services/api"},
+		{Path: "services/web", DependsOn: []string{"services/api"}},
+	}, c.Projects)
+}
+
+func (suite *ProjectsTestSuite) TestValidateProjects() {
+	var examples = []struct {
+		Content string
+		Error   string
+	}{
+		{"Projects:\n  - Path: ''\n", "Path empty for project"},
+		{"Projects:\n  - /services/api\n", "Project path /services/api must be a clean relative path inside the monorepo"},
+		{"Projects:\n  - ../api\n", "Project path ../api must be a clean relative path inside the monorepo"},
+		{"Projects:\n  - services/api/\n", "Project path services/api/ must be a clean relative path inside the monorepo"},
+		{"Projects:\n  - api\n  - api\n", "Project api listed more than once"},
+		{"Projects:\n  - Path: api\n    DependsOn: [base]\n", "Unknown dependency base for project api"},
+	}
+
+	for _, ex := range examples {
+		_, err := unmarshallConfig(ex.Content)
+
+		if assert.NotNil(suite.T(), err, ex.Content) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *ProjectsTestSuite) TestGetDockerfileBaseImages() {
+	assert.Equal(suite.T(), []string{"acme/base"}, getDockerfileBaseImages(filepath.Join(suite.dir, "services", "api")))
+	assert.Equal(suite.T(), []string{"alpine"}, getDockerfileBaseImages(filepath.Join(suite.dir, "libs", "base")))
+	assert.Nil(suite.T(), getDockerfileBaseImages(filepath.Join(suite.dir, "services", "web")))
+}
+
+func (suite *ProjectsTestSuite) TestGetProjects() {
+	projects, err := suite.r.GetProjects("", suite.images)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"libs/base", "services/api", "services/web"}, paths(projects))
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsChangedSince() {
+	suite.git.changed = []string{filepath.Join(suite.dir, "services", "api", "src", "main.go")}
+
+	projects, err := suite.r.GetProjects("origin/main", suite.images)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"services/api", "services/web"}, paths(projects))
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsChangedBaseImage() {
+	projects, err := suite.r.GetProjects("origin/main", suite.images)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"libs/base", "services/api", "services/web"}, paths(projects))
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsChangedConfig() {
+	suite.git.changed = []string{filepath.Join(suite.dir, "wrench.yml")}
+
+	projects, err := suite.r.GetProjects("origin/main", suite.images)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"libs/base", "services/api", "services/web"}, paths(projects))
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsUnchanged() {
+	suite.git.changed = []string{filepath.Join(suite.dir, "README.md")}
+
+	projects, err := suite.r.GetProjects("origin/main", suite.images)
+
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), projects)
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsChangedError() {
+	suite.git.changed_err = errors.New("Unable to find merge base of foobar and HEAD")
+
+	_, err := suite.r.GetProjects("foobar", suite.images)
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsMissingDirectory() {
+	suite.r.config.Projects = append(suite.r.config.Projects, SubProject{Path: "services/missing"})
+
+	_, err := suite.r.GetProjects("", suite.images)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Project directory services/missing not found", err.Error())
+	}
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsUnknownImage() {
+	delete(suite.images, "libs/base")
+
+	_, err := suite.r.GetProjects("", suite.images)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Image of project libs/base unknown", err.Error())
+	}
+}
+
+func (suite *ProjectsTestSuite) TestGetProjectsCycle() {
+	suite.r.config.Projects[2].DependsOn = []string{"services/web"}
+
+	_, err := suite.r.GetProjects("", suite.images)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Dependency cycle in projects: services/api -> libs/base -> services/web -> services/api", err.Error())
+	}
+}
+
+func (suite *ProjectsTestSuite) TestFindMonorepoProject() {
+	project_dir, file := suite.r.findMonorepoProject(filepath.Join(suite.dir, "services", "api", "src"))
+
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "services", "api"), project_dir)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "wrench.yml"), file)
+}
+
+func (suite *ProjectsTestSuite) TestFindMonorepoProjectNone() {
+	project_dir, file := suite.r.findMonorepoProject(filepath.Join(suite.dir, "services"))
+
+	assert.Equal(suite.T(), "", project_dir)
+	assert.Equal(suite.T(), "", file)
+}
+
+func (suite *ProjectsTestSuite) TestDiscoverProjectWithoutConfig() {
+	err := suite.r.discover(filepath.Join(suite.dir, "services", "api", "src"), "")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "", suite.r.config_file)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "wrench.yml"), suite.r.monorepo_config_file)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "services", "api"), suite.r.Dir())
+}
+
+func (suite *ProjectsTestSuite) TestDiscoverProjectWithConfig() {
+	err := suite.r.discover(filepath.Join(suite.dir, "services", "web"), "")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "services", "web", "wrench.yml"), suite.r.config_file)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "wrench.yml"), suite.r.monorepo_config_file)
+}
+
+func (suite *ProjectsTestSuite) TestLoadConfigMonorepoDefaults() {
+	r := newTestResolved(filepath.Join(suite.dir, "services", "web"), Config{}, nil)
+	r.config_file = filepath.Join(suite.dir, "services", "web", "wrench.yml")
+	r.monorepo_config_file = filepath.Join(suite.dir, "wrench.yml")
+
+	c, err := r.loadConfigFile()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), Project{Organization: "acme", Name: "webapp"}, c.Project)
+	assert.Nil(suite.T(), c.Projects)
+}
+
+func (suite *ProjectsTestSuite) TestHasChangesSince() {
+	r := newTestResolved(filepath.Join(suite.dir, "libs", "base"), Config{}, suite.git)
+
+	changed, err := r.HasChangesSince("origin/main")
+
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), changed)
+
+	r = newTestResolved(filepath.Join(suite.dir, "services", "api"), Config{}, suite.git)
+
+	changed, err = r.HasChangesSince("origin/main")
+
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), changed)
+}
+
+func (suite *ProjectsTestSuite) TestGetGitSemverTagPrefix() {
+	git := &gitCommand{}
+	var args []string
+	git.run = func(a ...string) (int, string) {
+		args = a
+		return 0, "api/v1.2.0-3-gabc1234\n"
+	}
+	r := newResolved(suite.dir, Options{Git: git, Host: fakeHost{}, Env: fakeEnv{}})
+	r.config = Config{Project: Project{Name: "api"}}
+	r.monorepo_config_file = filepath.Join(suite.dir, "wrench.yml")
+
+	version, err := r.getGitSemverTag()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "v1.2.0-3-gabc1234", version)
+	assert.Equal(suite.T(), []string{"describe", "--tags", "--match", "api/v[0-9]*.[0-9]*.[0-9]*"}, args)
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"text/template"
+
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Tag templates if not set in config, only the version
+var DefaultTagTemplates = []string{"{{.Version}}"}
+
+// Context for rendering tag templates
+type TagContext struct {
+	Version string
+	Branch  string
+	Git     GitInfo
+	Project Project
+}
+
+// Get image reference of project, fails if organization, name or version
+// don't form a valid image reference
+func (r *Resolved) GetProjectImageReference() (image.Reference, error) {
+	return image.Parse(r.GetProjectImage())
+}
+
+func (r *Resolved) getTagTemplates() []string {
+	if len(r.config.TagTemplates) == 0 {
+		return DefaultTagTemplates
+	}
+	return r.config.TagTemplates
+}
+
+// Get image tags for version from tag templates. Tags are normalised to
+// valid docker tags, tags that render empty or starting with a separator,
+// like "{{.Branch}}-latest" in detached HEAD, are skipped.
+func (r *Resolved) GetImageTags(version string) ([]string, error) {
+	tmpl_context := TagContext{
+		Version: version,
+		Branch:  r.git_info.Branch,
+		Git:     r.git_info,
+		Project: r.config.Project,
+	}
+
+	var tags []string
+	for _, tag_template := range r.getTagTemplates() {
+		tmpl := template.New("tag").Option("missingkey=error")
+		tmpl, err := tmpl.Funcs(r.getTemplateFuncs(tmpl)).Parse(tag_template)
+		if err != nil {
+			return nil, err
+		}
+
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, tmpl_context); err != nil {
+			return nil, err
+		}
+
+		rendered := strings.TrimSpace(out.String())
+		if rendered == "" || strings.HasPrefix(rendered, "-") || strings.HasPrefix(rendered, ".") {
+			log.Warningf("Skipping tag '%s' rendered from '%s'", rendered, tag_template)
+			continue
+		}
+
+		tag := image.NormalizeTag(rendered)
+		if !utils.StringInSlice(tag, tags) {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil, errors.New("No image tags rendered from TagTemplates")
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TagsTestSuite struct {
+	suite.Suite
+	r *Resolved
+}
+
+func TestTagsTestSuite(t *testing.T) {
+	suite.Run(t, new(TagsTestSuite))
+}
+
+func (suite *TagsTestSuite) SetupTest() {
+	suite.r = newTestResolved("/foobar", Config{
+		Project: Project{
+			Organization: "example",
+			Name:         "foobar",
+			Version:      "v1.0.0",
+		},
+	}, &fakeGit{sha: "abc1234def", short_sha: "abc1234", branch: "feature/Foo"})
+}
+
+func (suite *TagsTestSuite) TestGetImageTagsDefault() {
+	tags, err := suite.r.GetImageTags("v1.0.0")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"v1.0.0"}, tags)
+}
+
+func (suite *TagsTestSuite) TestGetImageTagsTemplates() {
+	suite.r.config.TagTemplates = []string{"{{.Version}}", "{{.Git.ShortSha}}", "{{.Branch}}-latest", "{{.Version}}"}
+
+	tags, err := suite.r.GetImageTags("1.2.1.dev5+gabc123")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"1.2.1.dev5-gabc123", "abc1234", "feature-Foo-latest"}, tags)
+}
+
+func (suite *TagsTestSuite) TestGetImageTagsSkipEmpty() {
+	suite.r.git_info.Branch = ""
+	suite.r.config.TagTemplates = []string{"{{.Version}}", "{{.Branch}}-latest"}
+
+	tags, err := suite.r.GetImageTags("v1.0.0")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"v1.0.0"}, tags)
+}
+
+func (suite *TagsTestSuite) TestGetImageTagsMissingKey() {
+	suite.r.config.TagTemplates = []string{"{{.Foobar}}"}
+
+	_, err := suite.r.GetImageTags("v1.0.0")
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *TagsTestSuite) TestGetProjectImageNormalized() {
+	r := newTestResolved("/foobar", Config{Project: Project{Organization: "Example", Name: "Foo Bar", Version: "1.2.1.dev5+gabc123"}}, nil)
+
+	assert.Nil(suite.T(), r.resolveProject())
+	assert.Equal(suite.T(), "example/foo-bar:1.2.1.dev5-gabc123", r.GetProjectImage())
+}
+
+func (suite *TagsTestSuite) TestValidateProjectImage() {
+	_, err := unmarshallConfig("Project:\n  Image: Example/foobar:v1\n")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Invalid Project Image: invalid image name 'Example/foobar', must be lowercase letters, digits and separators", err.Error())
+	}
+}
+
+func (suite *TagsTestSuite) TestGetProjectImageReferenceInvalid() {
+	r := newTestResolved("/foobar", Config{Project: Project{Image: "Example/Foo Bar:v1.0.0"}}, nil)
+
+	_, err := r.GetProjectImageReference()
+	assert.NotNil(suite.T(), err)
+
+	_, err = r.OutputConfig("env")
+	assert.NotNil(suite.T(), err)
+}
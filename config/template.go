@@ -0,0 +1,200 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+const templatesDirName = ".wrench/templates"
+
+// Render config file content as template. Content is rendered twice, first
+// to get Project values set in the content itself so they can be used in
+// the rest of the content.
+func (r *Resolved) render(content string, project Project) (string, error) {
+	return r.renderTemplate(content, project, false)
+}
+
+// Render content of config file from git repository as template, without
+// environment variables and functions reading the host
+func (r *Resolved) renderUntrusted(content string, project Project) (string, error) {
+	return r.renderTemplate(content, project, true)
+}
+
+func (r *Resolved) renderTemplate(content string, project Project, untrusted bool) (string, error) {
+	tmpl, err := r.parseConfigTemplate(content, untrusted)
+	if err != nil {
+		return "", err
+	}
+
+	environ := &r.environ
+	if untrusted {
+		environ = &map[string]string{}
+	}
+
+	// Get context for template
+	tmpl_context := TemplateContext{
+		Environ: environ,
+		Git:     r.git_info,
+		Project: project,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Wrench:  WrenchInfo{Version: WrenchVersion},
+	}
+
+	// Errors in first pass are reported by second pass if they remain
+	var first_pass bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&first_pass, "config", tmpl_context); err == nil {
+		if layer, err := parseConfig(first_pass.String()); err == nil {
+			tmpl_context.Project = mergeConfig(Config{Project: project}, layer).Project
+		}
+	}
+
+	// Render template with tmpl_context
+	var config_rendered bytes.Buffer
+	err = tmpl.ExecuteTemplate(&config_rendered, "config", tmpl_context)
+	if err != nil {
+		return "", err
+	}
+
+	return config_rendered.String(), nil
+}
+
+func (r *Resolved) parseConfigTemplate(content string, untrusted bool) (*template.Template, error) {
+	// Missing keys are errors instead of rendering "<no value>"
+	tmpl := template.New("config").Option("missingkey=error")
+	funcs := r.getTemplateFuncs(tmpl)
+	if untrusted {
+		funcs = getUntrustedTemplateFuncs(funcs)
+	}
+	tmpl.Funcs(funcs)
+
+	// Named templates shared by config files
+	for _, dir := range r.getTemplateDirs() {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if _, err := tmpl.ParseFiles(files...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Templates parsed from files are named after the file, parse config
+	// content last so it's what is executed
+	if _, err := tmpl.New("config").Parse(content); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Get git information, empty if not a git repository
+func readGitInfo(git Git) GitInfo {
+	info := GitInfo{}
+
+	if sha, err := git.Sha(); err == nil && sha != "" {
+		info.Sha = sha
+		info.ShortSha, _ = git.ShortSha()
+		info.Branch = git.Branch()
+		info.Tag = git.ExactTag()
+		info.Dirty = git.Dirty()
+	}
+
+	return info
+}
+
+// Get directories with named templates, global user templates first so
+// project templates can redefine them
+func (r *Resolved) getTemplateDirs() []string {
+	var dirs []string
+	if global := r.getGlobalConfigFile(); global != "" {
+		dirs = append(dirs, filepath.Join(filepath.Dir(global), "templates"))
+	}
+	return append(dirs, filepath.Join(r.dir, templatesDirName))
+}
+
+// Helper functions available in config templates, sprig functions like
+// default and indent with wrench specific functions on top
+func (r *Resolved) getTemplateFuncs(tmpl *template.Template) template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	for name, f := range r.wrenchTemplateFuncs(tmpl) {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+func (r *Resolved) wrenchTemplateFuncs(tmpl *template.Template) template.FuncMap {
+	return template.FuncMap{
+		// {{ env "REGISTRY" | required "REGISTRY must be set" }}
+		"required": func(message string, value interface{}) (string, error) {
+			s := templateString(value)
+			if s == "" {
+				return "", errors.New(message)
+			}
+			return s, nil
+		},
+		"env": func(name string) string {
+			return r.environ[name]
+		},
+		// File content relative to project directory
+		"file": func(path string) (string, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(r.dir, path)
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(string(content)), nil
+		},
+		"gitSha": func() (string, error) {
+			return r.git.ShortSha()
+		},
+		// Named template as string to be used in pipelines
+		// {{ include "python-run" . | indent 4 }}
+		"include": func(name string, data interface{}) (string, error) {
+			var out bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&out, name, data); err != nil {
+				return "", err
+			}
+			return out.String(), nil
+		},
+	}
+}
+
+// Functions reading environment variables, files or the network of the host
+var hostTemplateFuncs = []string{"env", "expandenv", "file", "getHostByName"}
+
+// Get template functions with functions reading the host replaced by
+// functions failing, so templates using them still parse
+func getUntrustedTemplateFuncs(funcs template.FuncMap) template.FuncMap {
+	untrusted := template.FuncMap{}
+	for name, f := range funcs {
+		untrusted[name] = f
+	}
+	for _, name := range hostTemplateFuncs {
+		name := name
+		untrusted[name] = func(args ...interface{}) (string, error) {
+			return "", errors.New(fmt.Sprintf("%s not allowed in git include", name))
+		}
+	}
+	return untrusted
+}
+
+func templateString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
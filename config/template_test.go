@@ -0,0 +1,123 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplateTestSuite struct {
+	suite.Suite
+	dir string
+	r   *Resolved
+}
+
+func TestTemplateTestSuite(t *testing.T) {
+	suite.Run(t, new(TemplateTestSuite))
+}
+
+func (suite *TemplateTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+
+	suite.r = newTestResolved(suite.dir, Config{},
+		&fakeGit{sha: "abc1234def", short_sha: "abc1234", branch: "main", tag: "v1.0.0"},
+		"REGISTRY=registry.example.com")
+	suite.r.git_info.Dirty = true
+}
+
+func (suite *TemplateTestSuite) TestEnvDefault() {
+	out, err := suite.r.render(`{{ env "REGISTRY" | default "localhost" }} {{ env "MISSING" | default "localhost" }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "registry.example.com localhost", out)
+}
+
+func (suite *TemplateTestSuite) TestMissingKey() {
+	_, err := suite.r.render(`{{ .Environ.MISSING }}`, Project{})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Contains(suite.T(), err.Error(), `map has no entry for key "MISSING"`)
+	}
+}
+
+func (suite *TemplateTestSuite) TestGitContext() {
+	out, err := suite.r.render(`{{ .Git.ShortSha }} {{ .Git.Branch }} {{ .Git.Tag }} {{ .Git.Dirty }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "abc1234 main v1.0.0 true", out)
+}
+
+func (suite *TemplateTestSuite) TestProjectContext() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		"Run:\n" +
+		"  push: docker push {{ .Project.Organization }}/{{ .Project.Name }}"
+
+	out, err := suite.r.render(content, Project{Organization: "acme"})
+
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), out, "docker push acme/foobar")
+}
+
+func (suite *TemplateTestSuite) TestWrenchContext() {
+	out, err := suite.r.render(`{{ .Wrench.Version }} {{ .OS | empty | not }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), WrenchVersion+" true", out)
+}
+
+func (suite *TemplateTestSuite) TestSprigFunctions() {
+	out, err := suite.r.render(`{{ "Foo" | lower | quote }} {{ list 1 2 | join "," }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), `"foo" 1,2`, out)
+}
+
+func (suite *TemplateTestSuite) TestRequired() {
+	_, err := suite.r.render(`{{ env "MISSING" | required "MISSING must be set" }}`, Project{})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Contains(suite.T(), err.Error(), "MISSING must be set")
+	}
+}
+
+func (suite *TemplateTestSuite) TestFile() {
+	ioutil.WriteFile(filepath.Join(suite.dir, "VERSION"), []byte("v1.2.3\n"), 0644)
+
+	out, err := suite.r.render(`{{ file "VERSION" }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "v1.2.3", out)
+}
+
+func (suite *TemplateTestSuite) TestGitSha() {
+	out, err := suite.r.render(`{{ gitSha }}`, Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "abc1234", out)
+}
+
+func (suite *TemplateTestSuite) TestNamedTemplates() {
+	os.MkdirAll(filepath.Join(suite.dir, templatesDirName), 0755)
+	ioutil.WriteFile(filepath.Join(suite.dir, templatesDirName, "python.tmpl"),
+		[]byte(`{{ define "python-run" }}lint: flake8
+unit: pytest{{ end }}`), 0644)
+
+	out, err := suite.r.render("Run:\n{{ include \"python-run\" . | indent 2 }}", Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "Run:\n  lint: flake8\n  unit: pytest", out)
+}
+
+func (suite *TemplateTestSuite) TestNamedTemplatesMissingDir() {
+	os.RemoveAll(suite.dir)
+
+	out, err := suite.r.render("Run:\n  unit: pytest", Project{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "Run:\n  unit: pytest", out)
+}
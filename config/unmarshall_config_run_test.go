@@ -220,3 +220,79 @@ func (suite *UnmarshalConfigRunTestSuite) TestUnmarshallConfigRunEnvValueNotStri
 	}
 	assert.Equal(suite.T(), "expanded", name)
 }
+
+func (suite *UnmarshalConfigRunTestSuite) TestUnmarshallConfigRunExpandedWithDockerOptions() {
+	volume_interface := make([]interface{}, 2)
+	volume_interface[0] = ".:/src"
+	volume_interface[1] = "gomod:/go/pkg/mod:rw"
+
+	item := yaml.MapItem{
+		Key: "foobar",
+		Value: yaml.MapSlice{
+			yaml.MapItem{Key: "Cmd", Value: "go test ./..."},
+			yaml.MapItem{Key: "Image", Value: "builder"},
+			yaml.MapItem{Key: "Entrypoint", Value: "/bin/bash"},
+			yaml.MapItem{Key: "Workdir", Value: "/src"},
+			yaml.MapItem{Key: "User", Value: "host"},
+			yaml.MapItem{Key: "Volumes", Value: volume_interface},
+		},
+	}
+	name, run, err := unmarshallConfigRun(item)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "foobar", name)
+	assert.Equal(suite.T(), "builder", run.Image)
+	assert.Equal(suite.T(), "/bin/bash", run.Entrypoint)
+	assert.Equal(suite.T(), "/src", run.Workdir)
+	assert.Equal(suite.T(), "host", run.User)
+	assert.Equal(suite.T(), []string{".:/src", "gomod:/go/pkg/mod:rw"}, run.Volumes)
+}
+
+func (suite *UnmarshalConfigRunTestSuite) TestUnmarshallConfigRunKeyNotString() {
+	item := yaml.MapItem{
+		Key: "foobar",
+		Value: yaml.MapSlice{
+			yaml.MapItem{Key: "Cmd", Value: "echo hello"},
+			yaml.MapItem{Key: 1, Value: "not a string key"},
+		},
+	}
+	name, _, err := unmarshallConfigRun(item)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unable to parse key as string for run item foobar", err.Error())
+	}
+	assert.Equal(suite.T(), "foobar", name)
+}
+
+func (suite *UnmarshalConfigRunTestSuite) TestUnmarshallConfigRunValidationErrors() {
+	var examples = []struct {
+		Key   string
+		Value interface{}
+		Error string
+	}{
+		{"Image", "unknown", "Image must be one of test, builder, final for run item foobar"},
+		{"Image", 1, "Unable to parse Image item as string for run item foobar"},
+		{"Workdir", "src", "Workdir must be an absolute path for run item foobar"},
+		{"Entrypoint", "/bin/sh -c", "Entrypoint must be a single executable for run item foobar"},
+		{"User", true, "Unable to parse User item as string for run item foobar"},
+		{"Volumes", ".:/src", "Unable to parse Volumes as list for run item foobar"},
+		{"Volumes", []interface{}{1}, "Unable to parse Volumes item as string for run item foobar"},
+		{"Volumes", []interface{}{"/src"}, "Unable to parse Volumes item '/src' as host:container[:options] for run item foobar"},
+		{"Volumes", []interface{}{".:src"}, "Unable to parse Volumes item '.:src' as host:container[:options] for run item foobar"},
+	}
+
+	for _, ex := range examples {
+		item := yaml.MapItem{
+			Key: "foobar",
+			Value: yaml.MapSlice{
+				yaml.MapItem{Key: "Cmd", Value: "echo hello"},
+				yaml.MapItem{Key: ex.Key, Value: ex.Value},
+			},
+		}
+		_, _, err := unmarshallConfigRun(item)
+
+		if assert.NotNil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
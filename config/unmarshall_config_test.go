@@ -227,3 +227,262 @@ func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigEnvValueNotString() {
 	}
 	assert.Equal(suite.T(), "expanded", config.Project.Name)
 }
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigExpandedWithDockerOptions() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		"  Organization: example\n" +
+		"  Version: v1.0.0\n" +
+		"Run:\n" +
+		"  foobar:\n" +
+		"    Cmd: go test ./...\n" +
+		"    Image: builder\n" +
+		"    Workdir: /src\n" +
+		"    User: 1000:1000\n" +
+		"    Volumes:\n" +
+		"      - .:/src\n"
+
+	config, err := unmarshallConfig(content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "builder", config.Run["foobar"].Image)
+	assert.Equal(suite.T(), "/src", config.Run["foobar"].Workdir)
+	assert.Equal(suite.T(), "1000:1000", config.Run["foobar"].User)
+	assert.Equal(suite.T(), []string{".:/src"}, config.Run["foobar"].Volumes)
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigServices() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		"Run:\n" +
+		"  integration:\n" +
+		"    Cmd: pytest\n" +
+		"    Services:\n" +
+		"      redis: redis:7\n" +
+		"      postgres:\n" +
+		"        Image: postgres:15\n" +
+		"        Cmd: postgres -c fsync=off\n" +
+		"        HealthCmd: pg_isready -U postgres\n" +
+		"        Env:\n" +
+		"          - POSTGRES_PASSWORD=secret\n"
+
+	config, err := unmarshallConfig(content)
+
+	if assert.Nil(suite.T(), err) {
+		services := config.Run["integration"].Services
+		assert.Equal(suite.T(), Service{Image: "redis:7"}, services["redis"])
+		assert.Equal(suite.T(), Service{
+			Image:     "postgres:15",
+			Cmd:       []string{"postgres", "-c", "fsync=off"},
+			HealthCmd: "pg_isready -U postgres",
+			Env:       []string{"POSTGRES_PASSWORD=secret"},
+		}, services["postgres"])
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallServiceCmd() {
+	content := "Run:\n" +
+		"  integration:\n" +
+		"    Cmd: pytest\n" +
+		"    Services:\n" +
+		"      postgres:\n" +
+		"        Image: postgres:15\n" +
+		"        Cmd: postgres -c \"shared_buffers=256MB\"\n" +
+		"      mysql:\n" +
+		"        Image: mysql:8\n" +
+		"        Cmd:\n" +
+		"          - mysqld\n" +
+		"          - --init-connect=SET NAMES utf8mb4\n"
+
+	config, err := unmarshallConfig(content)
+
+	if assert.Nil(suite.T(), err) {
+		services := config.Run["integration"].Services
+		assert.Equal(suite.T(), []string{"postgres", "-c", "shared_buffers=256MB"}, services["postgres"].Cmd)
+		assert.Equal(suite.T(), []string{"mysqld", "--init-connect=SET NAMES utf8mb4"}, services["mysql"].Cmd)
+	}
+
+	errors, _ := Validate("wrench.yml", content)
+	assert.Empty(suite.T(), errors)
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigServicesErrors() {
+	var examples = []struct {
+		Services string
+		Error    string
+	}{
+		{"    Services: redis\n", "Unable to parse Services as map for run item integration"},
+		{"    Services:\n      Redis: redis:7\n", "Invalid service name 'Redis' for run item integration"},
+		{"    Services:\n      redis: ''\n", "Image empty for service redis in run item integration"},
+		{"    Services:\n      redis:\n        - redis:7\n", "Unable to parse service redis as map for run item integration"},
+		{"    Services:\n      redis:\n        Image: redis:7\n        Env: FOO=BAR\n", "Unable to parse service redis Env as list for run item integration"},
+	}
+
+	for _, ex := range examples {
+		content := "Run:\n" +
+			"  integration:\n" +
+			"    Cmd: pytest\n" +
+			ex.Services
+
+		_, err := unmarshallConfig(content)
+
+		if assert.NotNil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigDependsOn() {
+	content := "Run:\n" +
+		"  lint: flake8\n" +
+		"  unit: pytest\n" +
+		"  ci:\n" +
+		"    DependsOn:\n" +
+		"      - lint\n" +
+		"      - unit\n"
+
+	config, err := unmarshallConfig(content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "", config.Run["ci"].Cmd)
+	assert.Equal(suite.T(), []string{"lint", "unit"}, config.Run["ci"].DependsOn)
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigDependsOnUnknown() {
+	content := "Run:\n" +
+		"  ci:\n" +
+		"    DependsOn:\n" +
+		"      - lint\n"
+
+	_, err := unmarshallConfig(content)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown dependency lint for run item ci", err.Error())
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigDependsOnCycle() {
+	content := "Run:\n" +
+		"  a:\n" +
+		"    Cmd: echo a\n" +
+		"    DependsOn: [b]\n" +
+		"  b:\n" +
+		"    Cmd: echo b\n" +
+		"    DependsOn: [c]\n" +
+		"  c:\n" +
+		"    Cmd: echo c\n" +
+		"    DependsOn: [b]\n"
+
+	_, err := unmarshallConfig(content)
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Dependency cycle in run items: b -> c -> b", err.Error())
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigParams() {
+	content := "Run:\n" +
+		"  unit:\n" +
+		"    Cmd: go test $PATTERN -count $COUNT -run $TEST\n" +
+		"    Params:\n" +
+		"      PATTERN: ./...\n" +
+		"      COUNT: 1\n" +
+		"      TEST:\n"
+
+	config, err := unmarshallConfig(content)
+
+	if assert.Nil(suite.T(), err) {
+		params := config.Run["unit"].Params
+		assert.Equal(suite.T(), "./...", *params["PATTERN"])
+		assert.Equal(suite.T(), "1", *params["COUNT"])
+		assert.Nil(suite.T(), params["TEST"])
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigParamsErrors() {
+	var examples = []struct {
+		Params string
+		Error  string
+	}{
+		{"    Params: [TEST]\n", "Unable to parse Params as map for run item unit"},
+		{"    Params:\n      test-name: foo\n", "Invalid param name 'test-name' for run item unit"},
+		{"    Params:\n      TEST: [foo]\n", "Unable to parse default of param TEST as string for run item unit"},
+	}
+
+	for _, ex := range examples {
+		content := "Run:\n" +
+			"  unit:\n" +
+			"    Cmd: go test\n" +
+			ex.Params
+
+		_, err := unmarshallConfig(content)
+
+		if assert.NotNil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigSecrets() {
+	content := "Secrets:\n" +
+		"  NPM_TOKEN:\n" +
+		"    Env: CI_NPM_TOKEN\n" +
+		"  DB_PASSWORD:\n" +
+		"    File: ~/.secrets/db\n" +
+		"  API_KEY:\n" +
+		"    Command: pass show api-key\n" +
+		"Run:\n" +
+		"  integration:\n" +
+		"    Cmd: pytest\n" +
+		"    Secrets: [DB_PASSWORD, API_KEY]\n"
+
+	config, err := unmarshallConfig(content)
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), Secret{Env: "CI_NPM_TOKEN"}, config.Secrets["NPM_TOKEN"])
+		assert.Equal(suite.T(), Secret{File: "~/.secrets/db"}, config.Secrets["DB_PASSWORD"])
+		assert.Equal(suite.T(), Secret{Command: "pass show api-key"}, config.Secrets["API_KEY"])
+		assert.Equal(suite.T(), []string{"DB_PASSWORD", "API_KEY"}, config.Run["integration"].Secrets)
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigSecretsErrors() {
+	var examples = []struct {
+		Content string
+		Error   string
+	}{
+		{"Secrets:\n  TOKEN: {}\n", "Secret TOKEN needs exactly one of Env, File or Command"},
+		{"Secrets:\n  TOKEN:\n    Env: FOO\n    File: foo\n", "Secret TOKEN needs exactly one of Env, File or Command"},
+		{"Secrets:\n  DOCKER_HOST:\n    Env: FOO\n", "Invalid secret name 'DOCKER_HOST'"},
+		{"Secrets:\n  api-key:\n    Env: FOO\n", "Invalid secret name 'api-key'"},
+		{"Run:\n  unit:\n    Cmd: pytest\n    Secrets: [TOKEN]\n", "Unknown secret TOKEN for run item unit"},
+	}
+
+	for _, ex := range examples {
+		_, err := unmarshallConfig(ex.Content)
+
+		if assert.NotNil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigVersioning() {
+	content := "Versioning:\n" +
+		"  Strategy: branch-sha\n" +
+		"  MainBranches: [main, develop]\n"
+
+	config, err := unmarshallConfig(content)
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), &Versioning{Strategy: "branch-sha", MainBranches: []string{"main", "develop"}}, config.Versioning)
+	}
+}
+
+func (suite *UnmarshalConfigTestSuite) TestUnmarshallConfigVersioningUnknown() {
+	_, err := unmarshallConfig("Versioning:\n  Strategy: foobar\n")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Versioning Strategy must be one of describe, branch-sha, calver, pep440, maven", err.Error())
+	}
+}
@@ -0,0 +1,354 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tomologic/wrench/utils"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// JSON Schema for wrench.yml, published at the $id of the schema
+//
+//go:embed schema.json
+var Schema []byte
+
+// Subset of JSON Schema used by schema.json
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaType         `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schemaOrBool      `json:"additionalProperties"`
+	PropertyNames        *schema            `json:"propertyNames"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	OneOf                []*schema          `json:"oneOf"`
+	Definitions          map[string]*schema `json:"definitions"`
+}
+
+// Type is either a single type or a list of types
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// AdditionalProperties is either a boolean or a schema
+type schemaOrBool struct {
+	Allowed bool
+	Schema  *schema
+}
+
+func (s *schemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Allowed); err == nil {
+		return nil
+	}
+	s.Allowed = true
+	return json.Unmarshal(data, &s.Schema)
+}
+
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+
+	// Unknown keys are only warnings when loading config
+	UnknownKey bool
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+type validator struct {
+	file   string
+	root   *schema
+	errors []ValidationError
+}
+
+var yamlLineRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Validate wrench.yml content against schema. Errors are sorted by position.
+func Validate(file string, content string) ([]ValidationError, error) {
+	root := &schema{}
+	if err := json.Unmarshal(Schema, root); err != nil {
+		return nil, err
+	}
+
+	v := &validator{file: file, root: root}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(content), &document); err != nil {
+		// Report syntax errors with line number like other errors
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if match := yamlLineRegexp.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+				num, _ := strconv.Atoi(match[1])
+				v.addError(&yamlv3.Node{Line: num, Column: 1}, "%s", match[2])
+			}
+		}
+		if len(v.errors) == 0 {
+			v.addError(&yamlv3.Node{Line: 1, Column: 1}, "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+		return v.errors, nil
+	}
+
+	// Empty file is valid
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	v.validate(document.Content[0], root, "")
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].Line != v.errors[j].Line {
+			return v.errors[i].Line < v.errors[j].Line
+		}
+		return v.errors[i].Column < v.errors[j].Column
+	})
+
+	return v.errors, nil
+}
+
+func (v *validator) addError(node *yamlv3.Node, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{
+		File:    v.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) resolve(s *schema) *schema {
+	for s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		s = v.root.Definitions[name]
+	}
+	return s
+}
+
+// Get JSON Schema type of yaml node
+func getNodeType(node *yamlv3.Node) string {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		return "object"
+	case yamlv3.SequenceNode:
+		return "array"
+	case yamlv3.AliasNode:
+		return getNodeType(node.Alias)
+	}
+	switch node.Tag {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	}
+	return "string"
+}
+
+// Check if schema allows node type. Any scalar can be used as string, like
+// Version: 1.0, and integers as numbers.
+func typeMatches(s *schema, node_type string) bool {
+	if len(s.Type) == 0 {
+		return true
+	}
+	for _, t := range s.Type {
+		if t == node_type || t == "number" && node_type == "integer" ||
+			t == "string" && node_type != "object" && node_type != "array" && node_type != "null" {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) validate(node *yamlv3.Node, s *schema, path string) {
+	s = v.resolve(s)
+
+	if node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+
+	node_type := getNodeType(node)
+
+	if len(s.OneOf) > 0 {
+		// Options of the exact type are preferred over a string option
+		// taking any scalar
+		for _, option := range s.OneOf {
+			option = v.resolve(option)
+			if utils.StringInSlice(node_type, option.Type) {
+				v.validate(node, option, path)
+				return
+			}
+		}
+
+		var types []string
+		for _, option := range s.OneOf {
+			option = v.resolve(option)
+			if typeMatches(option, node_type) {
+				v.validate(node, option, path)
+				return
+			}
+			types = append(types, option.Type...)
+		}
+		v.addError(node, "%s must be %s, got %s", describePath(path), strings.Join(types, " or "), node_type)
+		return
+	}
+
+	if !typeMatches(s, node_type) {
+		v.addError(node, "%s must be %s, got %s", describePath(path), strings.Join(s.Type, " or "), node_type)
+		return
+	}
+
+	switch node_type {
+	case "object":
+		v.validateObject(node, s, path)
+	case "array":
+		if s.Items != nil {
+			for i, item := range node.Content {
+				v.validate(item, s.Items, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "null":
+	default:
+		if len(s.Enum) > 0 && !utils.StringInSlice(node.Value, s.Enum) {
+			v.addError(node, "%s must be one of %s, got %q", describePath(path), strings.Join(s.Enum, ", "), node.Value)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(node.Value) {
+			v.addError(node, "%s %q does not match %s", describePath(path), node.Value, s.Pattern)
+		}
+	}
+}
+
+func (v *validator) validateObject(node *yamlv3.Node, s *schema, path string) {
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		value := node.Content[i+1]
+
+		if key.Tag != "!!str" {
+			v.addError(key, "key %s in %s must be string", key.Value, describePath(path))
+			continue
+		}
+		seen[key.Value] = true
+
+		key_path := key.Value
+		if path != "" {
+			key_path = path + "." + key.Value
+		}
+
+		if s.PropertyNames != nil && s.PropertyNames.Pattern != "" &&
+			!regexp.MustCompile(s.PropertyNames.Pattern).MatchString(key.Value) {
+			v.addError(key, "invalid name %q in %s, must match %s", key.Value, describePath(path), s.PropertyNames.Pattern)
+			continue
+		}
+
+		if property, ok := s.Properties[key.Value]; ok {
+			v.validate(value, property, key_path)
+			continue
+		}
+
+		if s.AdditionalProperties == nil || s.AdditionalProperties.Schema == nil && s.AdditionalProperties.Allowed {
+			continue
+		}
+
+		if s.AdditionalProperties.Schema != nil {
+			v.validate(value, s.AdditionalProperties.Schema, key_path)
+			continue
+		}
+
+		message := fmt.Sprintf("unknown key %q in %s", key.Value, describePath(path))
+		if suggestion := suggestKey(key.Value, s.Properties); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		v.addError(key, "%s", message)
+		v.errors[len(v.errors)-1].UnknownKey = true
+	}
+
+	for _, required := range s.Required {
+		if !seen[required] {
+			v.addError(node, "missing required key %q in %s", required, describePath(path))
+		}
+	}
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "wrench.yml"
+	}
+	return path
+}
+
+// Get closest known key if it's likely a typo
+func suggestKey(key string, properties map[string]*schema) string {
+	best := ""
+	best_distance := 3
+
+	for property := range properties {
+		if strings.EqualFold(property, key) {
+			return property
+		}
+
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(property))
+		if distance < best_distance || distance == best_distance && property < best {
+			best = property
+			best_distance = distance
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
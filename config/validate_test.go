@@ -0,0 +1,164 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidateTestSuite struct {
+	suite.Suite
+}
+
+func TestValidateTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidateTestSuite))
+}
+
+func getMessages(errors []ValidationError) []string {
+	var messages []string
+	for _, e := range errors {
+		messages = append(messages, e.Error())
+	}
+	return messages
+}
+
+func (suite *ValidateTestSuite) TestValidateValid() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		"  Version: 1.0\n" +
+		"Secrets:\n" +
+		"  TOKEN:\n" +
+		"    Env: CI_TOKEN\n" +
+		"Run:\n" +
+		"  simple: echo simple\n" +
+		"  expanded:\n" +
+		"    Cmd: echo $FOO\n" +
+		"    Image: test\n" +
+		"    Env:\n" +
+		"      - FOO=BAR\n" +
+		"    Params:\n" +
+		"      TEST:\n" +
+		"    Services:\n" +
+		"      redis: redis:7\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), errors)
+}
+
+func (suite *ValidateTestSuite) TestValidateEmpty() {
+	errors, err := Validate("wrench.yml", "")
+
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), errors)
+}
+
+func (suite *ValidateTestSuite) TestValidateUnknownKeySuggestion() {
+	content := "Project:\n" +
+		"  Organisation: example\n" +
+		"  name: foobar\n" +
+		"Run:\n" +
+		"  unit:\n" +
+		"    Cmd: pytest\n" +
+		"    DependOn: [lint]\n" +
+		"    Metadata: foo\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		`wrench.yml:2:3: unknown key "Organisation" in Project, did you mean "Organization"?`,
+		`wrench.yml:3:3: unknown key "name" in Project, did you mean "Name"?`,
+		`wrench.yml:7:5: unknown key "DependOn" in Run.unit, did you mean "DependsOn"?`,
+		`wrench.yml:8:5: unknown key "Metadata" in Run.unit`,
+	}, getMessages(errors))
+	for _, e := range errors {
+		assert.True(suite.T(), e.UnknownKey)
+	}
+}
+
+func (suite *ValidateTestSuite) TestValidateTypes() {
+	content := "Project:\n" +
+		"  Name: [foobar]\n" +
+		"Run:\n" +
+		"  - bash\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		"wrench.yml:2:9: Project.Name must be string, got array",
+		"wrench.yml:4:3: Run must be object, got array",
+	}, getMessages(errors))
+}
+
+func (suite *ValidateTestSuite) TestValidateScalarTypes() {
+	content := "Project:\n" +
+		"  Version: 1.0\n" +
+		"  Name: true\n" +
+		"Cache:\n" +
+		"  Previous: false\n" +
+		"Build:\n" +
+		"  Ssh: default\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		"wrench.yml:7:8: Build.Ssh must be array, got string",
+	}, getMessages(errors))
+
+	errors, _ = Validate("wrench.yml", "Cache:\n  Previous: yes please\n")
+	assert.Equal(suite.T(), []string{
+		"wrench.yml:2:13: Cache.Previous must be boolean, got string",
+	}, getMessages(errors))
+}
+
+func (suite *ValidateTestSuite) TestValidateRunItem() {
+	content := "Run:\n" +
+		"  true: bash\n" +
+		"  list:\n" +
+		"    - bash\n" +
+		"  expanded:\n" +
+		"    Cmd: bash\n" +
+		"    Image: production\n" +
+		"    Workdir: src\n" +
+		"    Env: FOO=BAR\n" +
+		"    Services:\n" +
+		"      db:\n" +
+		"        Cmd: postgres\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{
+		"wrench.yml:2:3: key true in Run must be string",
+		"wrench.yml:4:5: Run.list must be string or object, got array",
+		`wrench.yml:7:12: Run.expanded.Image must be one of test, builder, final, got "production"`,
+		`wrench.yml:8:14: Run.expanded.Workdir "src" does not match ^/`,
+		"wrench.yml:9:10: Run.expanded.Env must be array, got string",
+		`wrench.yml:12:9: missing required key "Image" in Run.expanded.Services.db`,
+	}, getMessages(errors))
+}
+
+func (suite *ValidateTestSuite) TestValidateSyntaxError() {
+	content := "Project:\n" +
+		"  Name: foobar\n" +
+		" Organization: example\n"
+
+	errors, err := Validate("wrench.yml", content)
+
+	assert.Nil(suite.T(), err)
+	if assert.Len(suite.T(), errors, 1) {
+		assert.Equal(suite.T(), "wrench.yml:2:1: did not find expected key", errors[0].Error())
+	}
+}
+
+func (suite *ValidateTestSuite) TestLevenshtein() {
+	assert.Equal(suite.T(), 0, levenshtein("Run", "Run"))
+	assert.Equal(suite.T(), 1, levenshtein("Organisation", "Organization"))
+	assert.Equal(suite.T(), 3, levenshtein("kitten", "sitting"))
+	assert.Equal(suite.T(), 3, levenshtein("", "Cmd"))
+}
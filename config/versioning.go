@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/semver"
+)
+
+// Tag prefix if not set in config, tags like v1.2.3
+const DefaultTagPrefix = "v"
+
+var tagPrefixRegexp = regexp.MustCompile("^[A-Za-z0-9._/-]*$")
+
+func validateVersioningTags(versioning *Versioning) error {
+	if versioning == nil {
+		return nil
+	}
+
+	if prefix := versioning.TagPrefix; prefix != nil &&
+		(!tagPrefixRegexp.MatchString(*prefix) || strings.Contains(*prefix, "..") || strings.HasPrefix(*prefix, "/")) {
+		return errors.New(fmt.Sprintf("Versioning TagPrefix '%s' must only contain letters, digits, '.', '_', '/' and '-'", *prefix))
+	}
+
+	if strings.ContainsAny(versioning.TagPattern, " \t\n") {
+		return errors.New(fmt.Sprintf("Versioning TagPattern '%s' must not contain whitespace", versioning.TagPattern))
+	}
+
+	return nil
+}
+
+// Get part of version tags before the version. Projects in a monorepo
+// have their name before the prefix, like api/v1.2.3.
+func (r *Resolved) GetTagPrefix() string {
+	prefix := DefaultTagPrefix
+	if r.config.Versioning != nil && r.config.Versioning.TagPrefix != nil {
+		prefix = *r.config.Versioning.TagPrefix
+	}
+
+	if r.monorepo_config_file != "" {
+		prefix = r.GetProjectName() + "/" + prefix
+	}
+
+	return prefix
+}
+
+// Get glob matching version tags, only matching tags are considered by
+// version detection and bump
+func (r *Resolved) GetTagPattern() string {
+	if r.config.Versioning != nil && r.config.Versioning.TagPattern != "" {
+		return r.config.Versioning.TagPattern
+	}
+	return r.GetTagPrefix() + "[0-9]*.[0-9]*.[0-9]*"
+}
+
+// Get git tag for version
+func (r *Resolved) GetTagName(version semver.Semver) string {
+	return r.GetTagPrefix() + strings.TrimPrefix(version.String(), "v")
+}
+
+// Get version from tag or git describe output of tag. Versions start with
+// v regardless of tag prefix, tag release-1.2.3 is version v1.2.3.
+func (r *Resolved) parseTagVersion(tag string) (string, error) {
+	prefix := r.GetTagPrefix()
+	if !strings.HasPrefix(tag, prefix) {
+		return "", errors.New(fmt.Sprintf("Tag %s doesn't start with TagPrefix '%s'", tag, prefix))
+	}
+
+	version := "v" + strings.TrimPrefix(tag, prefix)
+	if _, err := semver.Parse(version); err != nil {
+		return "", errors.New(fmt.Sprintf("Tag %s has no semver version after TagPrefix '%s': %s", tag, prefix, err))
+	}
+
+	return version, nil
+}
+
+// Get versions of all version tags, tags matching TagPattern without a
+// semver version after TagPrefix are skipped
+func (r *Resolved) GetVersionTags() (semver.SemverList, error) {
+	tags, err := r.git.Tags(r.GetTagPattern())
+	if err != nil {
+		return nil, err
+	}
+
+	var versions semver.SemverList
+	for _, tag := range tags {
+		version, err := r.parseTagVersion(tag)
+		if err != nil {
+			log.Warningf("Skipping tag: %s", err)
+			continue
+		}
+
+		// Parsed in parseTagVersion
+		v, _ := semver.Parse(version)
+		versions = append(versions, v)
+	}
+
+	return versions, nil
+}
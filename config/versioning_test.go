@@ -0,0 +1,170 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/semver"
+	"github.com/tomologic/wrench/utils"
+)
+
+type VersioningTestSuite struct {
+	suite.Suite
+	r *Resolved
+}
+
+func TestVersioningTestSuite(t *testing.T) {
+	suite.Run(t, new(VersioningTestSuite))
+}
+
+func (suite *VersioningTestSuite) SetupTest() {
+	dir := suite.T().TempDir()
+
+	suite.r = newResolved(dir, Options{Host: fakeHost{}, Env: fakeEnv{}})
+	suite.r.config = Config{Project: Project{Name: "api"}}
+}
+
+// Create git repository in project directory with tags on the first of
+// two commits
+func (suite *VersioningTestSuite) createRepo(tags ...string) {
+	git := "git -C " + utils.ShellQuote(suite.r.Dir())
+	commands := []string{
+		git + " init -q",
+		git + " -c user.name=wrench -c user.email=wrench@example.com commit -q --allow-empty -m first",
+	}
+	for _, tag := range tags {
+		commands = append(commands, git+" tag "+utils.ShellQuote(tag))
+	}
+	commands = append(commands, git+" -c user.name=wrench -c user.email=wrench@example.com commit -q --allow-empty -m second")
+
+	for _, command := range commands {
+		exitcode, out := utils.RunCmd(command)
+		if !assert.Equal(suite.T(), 0, exitcode, out) {
+			suite.T().FailNow()
+		}
+	}
+}
+
+func (suite *VersioningTestSuite) setTagPrefix(prefix string) {
+	suite.r.config.Versioning = &Versioning{TagPrefix: &prefix}
+}
+
+func (suite *VersioningTestSuite) TestTagConventions() {
+	var examples = []struct {
+		Prefix    *string
+		Monorepo  bool
+		Tag       string
+		Expected  string
+		Other     string
+		ReleaseAs string
+	}{
+		{nil, false, "v1.2.3", "v1.2.3", "release-2.0.0", "v1.3.0"},
+		{strPtr("release-"), false, "release-1.2.3", "v1.2.3", "v2.0.0", "release-1.3.0"},
+		{strPtr(""), false, "1.2.3", "v1.2.3", "v2.0.0", "1.3.0"},
+		{strPtr("service/v"), false, "service/v1.2.3", "v1.2.3", "other/v2.0.0", "service/v1.3.0"},
+		{nil, true, "api/v1.2.3", "v1.2.3", "web/v2.0.0", "api/v1.3.0"},
+	}
+
+	for _, ex := range examples {
+		suite.SetupTest()
+		suite.r.config.Versioning = &Versioning{TagPrefix: ex.Prefix}
+		if ex.Monorepo {
+			suite.r.monorepo_config_file = "wrench.yml"
+		}
+
+		suite.createRepo(ex.Tag, ex.Other)
+
+		version, err := suite.r.getGitSemverTag()
+		if assert.Nil(suite.T(), err, ex.Tag) {
+			assert.Regexp(suite.T(), regexp.MustCompile("^"+regexp.QuoteMeta(ex.Expected)+"-1-g[0-9a-f]+$"), version)
+		}
+
+		versions, err := suite.r.GetVersionTags()
+		if assert.Nil(suite.T(), err, ex.Tag) {
+			assert.Equal(suite.T(), semver.SemverList{{Major: 1, Minor: 2, Patch: 3}}, versions)
+		}
+
+		assert.Equal(suite.T(), ex.ReleaseAs, suite.r.GetTagName(semver.Semver{Major: 1, Minor: 3, Patch: 0}))
+	}
+}
+
+func (suite *VersioningTestSuite) TestTagPattern() {
+	suite.r.config.Versioning = &Versioning{TagPattern: "v[0-9]*.[0-9]*.[0-9]*-final"}
+	suite.createRepo("v1.2.3-final", "v1.3.0")
+
+	version, err := suite.r.getGitSemverTag()
+	if assert.Nil(suite.T(), err) {
+		assert.Regexp(suite.T(), "^v1.2.3-final-1-g[0-9a-f]+$", version)
+	}
+
+	versions, err := suite.r.GetVersionTags()
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), semver.SemverList{{Major: 1, Minor: 2, Patch: 3, Snapshot: "final"}}, versions)
+	}
+}
+
+func (suite *VersioningTestSuite) TestTagPatternWithoutPrefix() {
+	suite.setTagPrefix("release-")
+	suite.r.config.Versioning.TagPattern = "*"
+	suite.createRepo("release-1.2.3", "latest", "release-foo")
+
+	versions, err := suite.r.GetVersionTags()
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), semver.SemverList{{Major: 1, Minor: 2, Patch: 3}}, versions)
+	}
+}
+
+func (suite *VersioningTestSuite) TestNoVersionTag() {
+	suite.setTagPrefix("release-")
+	suite.createRepo("v1.2.3")
+
+	_, err := suite.r.getGitSemverTag()
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *VersioningTestSuite) TestParseVersioning() {
+	c, err := unmarshallConfig("Versioning:\n  TagPrefix: ''\n  TagPattern: '[0-9]*'\n")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "", *c.Versioning.TagPrefix)
+		assert.Equal(suite.T(), "[0-9]*", c.Versioning.TagPattern)
+	}
+}
+
+func (suite *VersioningTestSuite) TestValidateTagPrefix() {
+	var examples = []struct {
+		Content string
+		Error   string
+	}{
+		{"Versioning:\n  TagPrefix: 'release 1'\n", "Versioning TagPrefix 'release 1' must only contain letters, digits, '.', '_', '/' and '-'"},
+		{"Versioning:\n  TagPrefix: 'release..'\n", "Versioning TagPrefix 'release..' must only contain letters, digits, '.', '_', '/' and '-'"},
+		{"Versioning:\n  TagPattern: 'v* final'\n", "Versioning TagPattern 'v* final' must not contain whitespace"},
+	}
+
+	for _, ex := range examples {
+		_, err := unmarshallConfig(ex.Content)
+
+		if assert.NotNil(suite.T(), err, ex.Content) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *VersioningTestSuite) TestMergeTagPrefix() {
+	base := Config{Versioning: &Versioning{TagPrefix: strPtr("release-"), TagPattern: "release-*"}}
+
+	merged := mergeConfig(base, Config{Versioning: &Versioning{Strategy: "pep440"}})
+	assert.Equal(suite.T(), "release-", *merged.Versioning.TagPrefix)
+	assert.Equal(suite.T(), "release-*", merged.Versioning.TagPattern)
+
+	merged = mergeConfig(base, Config{Versioning: &Versioning{TagPrefix: strPtr("")}})
+	assert.Equal(suite.T(), "", *merged.Versioning.TagPrefix)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -9,7 +9,7 @@ _wrench()
     #
     #  The basic options we'll complete.
     #
-    opts="build bump config help push run version -h --help"
+    opts="build bump clean config exec help push run shell version -h --help -C --directory --config -v --verbose -q --quiet --log-format"
 
 
     #
@@ -17,7 +17,7 @@ _wrench()
     #
     case "${prev}" in
         build)
-            local build_opts="-h --help -r --rebuild"
+            local build_opts="-h --help -r --rebuild --changed-since --report --cache-from --cache-to --ssh --secret"
             COMPREPLY=($(compgen -W "${build_opts}" -- "${cur}"))
             return 0
             ;;
@@ -26,17 +26,54 @@ _wrench()
             COMPREPLY=($(compgen -W "${bump_opts}" -- "${cur}"))
             return 0
             ;;
+        clean)
+            local clean_opts="-n --dry-run --min-age --snapshots-older-than --keep-snapshots -h --help"
+            COMPREPLY=($(compgen -W "${clean_opts}" -- "${cur}"))
+            return 0
+            ;;
         config)
-            local config_opts="--format -h --help"
+            local config_opts="validate schema projects --format -o --output --show-origin -h --help"
             COMPREPLY=($(compgen -W "${config_opts}" -- "${cur}"))
             return 0
             ;;
         push)
-            local push_opts="--additional-tags -h --help"
+            local push_opts="--additional-tags --report -h --help"
             COMPREPLY=($(compgen -W "${push_opts}" -- "${cur}"))
             return 0
             ;;
-        run)
+        -j|--jobs|-p|--param)
+            return 0
+            ;;
+        -C|--directory)
+            COMPREPLY=($(compgen -d -- "${cur}"))
+            return 0
+            ;;
+        --config|--report)
+            COMPREPLY=($(compgen -f -- "${cur}"))
+            return 0
+            ;;
+        --report-format)
+            COMPREPLY=($(compgen -W "json junit" -- "${cur}"))
+            return 0
+            ;;
+        --log-format)
+            COMPREPLY=($(compgen -W "text json" -- "${cur}"))
+            return 0
+            ;;
+        shell|exec)
+            local shell_opts="--image --run -h --help"
+            COMPREPLY=($(compgen -W "${shell_opts}" -- "${cur}"))
+            return 0
+            ;;
+        --image)
+            COMPREPLY=($(compgen -W "test builder final" -- "${cur}"))
+            return 0
+            ;;
+        -o|--output)
+            COMPREPLY=($(compgen -W "yaml json env export github" -- "${cur}"))
+            return 0
+            ;;
+        run|--run)
             local wrench_run_config wrench_run_targets
             local regex='[0-9A-Za-z-]+:'
             wrench_run_config=$(wrench config --format '{{.Run}}')
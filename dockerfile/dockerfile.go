@@ -0,0 +1,216 @@
+// Package dockerfile parses Dockerfiles into instructions with the lines
+// they span, handling parser directives, comments, line continuations and
+// heredocs, so instructions can be found and replaced without changing the
+// rest of the file.
+package dockerfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Instruction of Dockerfile
+type Instruction struct {
+	// Upper case, like FROM
+	Command string
+
+	// Arguments with continuation lines joined, heredoc content excluded
+	Args string
+
+	// First and last line of instruction, starting at 1
+	Line    int
+	EndLine int
+}
+
+type Dockerfile struct {
+	// Parser directives by lower case name, like syntax
+	Directives map[string]string
+
+	Instructions []Instruction
+
+	lines []string
+
+	// Number of lines of parser directives
+	directive_lines int
+}
+
+// Known parser directives, others are comments
+var directives = map[string]bool{"syntax": true, "escape": true, "check": true}
+
+// Instructions with heredoc support
+var heredocCommands = map[string]bool{"RUN": true, "COPY": true, "ADD": true}
+
+var directiveRegexp = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9]*)\s*=\s*(.*?)\s*$`)
+var heredocRegexp = regexp.MustCompile(`<<(-?)\s*(["']?)([a-zA-Z_][a-zA-Z0-9_]*)(["']?)`)
+var commandRegexp = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// Parse Dockerfile content, errors have the line of the problem
+func Parse(content string) (*Dockerfile, error) {
+	d := &Dockerfile{
+		Directives: map[string]string{},
+		lines:      strings.Split(content, "\n"),
+	}
+
+	// Parser directives are only allowed before any comment, empty line or
+	// instruction
+	for _, line := range d.lines {
+		match := directiveRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || !directives[strings.ToLower(match[1])] {
+			break
+		}
+		name := strings.ToLower(match[1])
+		if _, ok := d.Directives[name]; ok {
+			return nil, fmt.Errorf("line %d: parser directive %s set twice", d.directive_lines+1, name)
+		}
+		d.Directives[name] = match[2]
+		d.directive_lines += 1
+	}
+
+	escape := byte('\\')
+	if value, ok := d.Directives["escape"]; ok {
+		if value != "\\" && value != "`" {
+			return nil, fmt.Errorf("invalid escape directive '%s', must be \\ or `", value)
+		}
+		escape = value[0]
+	}
+
+	i := d.directive_lines
+	for i < len(d.lines) {
+		line := strings.TrimSpace(strings.TrimSuffix(d.lines[i], "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			i += 1
+			continue
+		}
+
+		instruction := Instruction{Line: i + 1}
+
+		// Join continuation lines, comments and empty lines between them
+		// are skipped
+		var parts []string
+		end := i
+		for {
+			line := strings.TrimSpace(strings.TrimSuffix(d.lines[end], "\r"))
+			if end == i || (line != "" && !strings.HasPrefix(line, "#")) {
+				continued := line[len(line)-1] == escape
+				if continued {
+					line = strings.TrimSpace(line[:len(line)-1])
+				}
+				if line != "" {
+					parts = append(parts, line)
+				}
+				if !continued {
+					break
+				}
+			}
+			if end+1 == len(d.lines) {
+				break
+			}
+			end += 1
+		}
+		i = end + 1
+
+		fields := strings.SplitN(strings.Join(parts, " "), " ", 2)
+		if !commandRegexp.MatchString(fields[0]) {
+			return nil, fmt.Errorf("line %d: unknown instruction '%s'", instruction.Line, fields[0])
+		}
+		instruction.Command = strings.ToUpper(fields[0])
+		if len(fields) == 2 {
+			instruction.Args = strings.TrimSpace(fields[1])
+		}
+
+		// Skip heredoc content, which may contain anything
+		if heredocCommands[instruction.Command] {
+			for _, match := range heredocRegexp.FindAllStringSubmatch(instruction.Args, -1) {
+				if match[2] != match[4] {
+					continue
+				}
+				for {
+					if i == len(d.lines) {
+						return nil, fmt.Errorf("line %d: heredoc %s is not terminated", instruction.Line, match[3])
+					}
+					content := strings.TrimSuffix(d.lines[i], "\r")
+					i += 1
+					if match[1] == "-" {
+						content = strings.TrimLeft(content, "\t")
+					}
+					if content == match[3] {
+						break
+					}
+				}
+			}
+		}
+
+		instruction.EndLine = i
+		d.Instructions = append(d.Instructions, instruction)
+	}
+
+	return d, nil
+}
+
+// Replace lines of instruction with text. Continuation lines are replaced
+// by empty lines so the other instructions keep their line numbers.
+func (d *Dockerfile) Replace(instruction Instruction, text string) {
+	d.lines[instruction.Line-1] = text
+	for line := instruction.Line; line < instruction.EndLine; line++ {
+		d.lines[line] = ""
+	}
+}
+
+// Insert lines after parser directives, before every instruction. Line
+// numbers of instructions are not updated.
+func (d *Dockerfile) InsertFirst(lines ...string) {
+	d.lines = append(d.lines[:d.directive_lines], append(lines, d.lines[d.directive_lines:]...)...)
+}
+
+func (d *Dockerfile) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// Arguments of FROM instruction
+type From struct {
+	// Flags like --platform=linux/amd64
+	Flags []string
+
+	// Base image, may be empty in Dockerfile.test
+	Image string
+
+	// Name of stage after AS, empty if not named
+	Name string
+}
+
+// Parse arguments of FROM instruction, [--flag...] [image] [AS name]
+func ParseFrom(args string) (From, error) {
+	from := From{}
+
+	fields := strings.Fields(args)
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		from.Flags = append(from.Flags, fields[0])
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && !strings.EqualFold(fields[0], "AS") {
+		from.Image = fields[0]
+		fields = fields[1:]
+	}
+
+	switch {
+	case len(fields) == 0:
+	case len(fields) == 2 && strings.EqualFold(fields[0], "AS"):
+		from.Name = fields[1]
+	default:
+		return From{}, fmt.Errorf("invalid FROM '%s', expected [--flag...] image [AS name]", args)
+	}
+
+	return from, nil
+}
+
+// Format as FROM instruction
+func (f From) String() string {
+	fields := append([]string{"FROM"}, f.Flags...)
+	fields = append(fields, f.Image)
+	if f.Name != "" {
+		fields = append(fields, "AS", f.Name)
+	}
+	return strings.Join(fields, " ")
+}
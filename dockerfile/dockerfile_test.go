@@ -0,0 +1,115 @@
+package dockerfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DockerfileTestSuite struct {
+	suite.Suite
+}
+
+func TestDockerfileTestSuite(t *testing.T) {
+	suite.Run(t, new(DockerfileTestSuite))
+}
+
+const content = `# syntax=docker/dockerfile:1
+# escape=\
+# Test image
+ARG PYTHON=3.12
+
+FROM --platform=linux/amd64 python:${PYTHON} AS base
+RUN apt-get update && \
+    # Comment inside continuation
+    apt-get install -y \
+
+      curl
+COPY <<EOF /etc/test.conf
+FROM nothing
+EOF
+from base
+`
+
+func (suite *DockerfileTestSuite) TestParse() {
+	d, err := Parse(content)
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), map[string]string{"syntax": "docker/dockerfile:1", "escape": "\\"}, d.Directives)
+		assert.Equal(suite.T(), []Instruction{
+			{Command: "ARG", Args: "PYTHON=3.12", Line: 4, EndLine: 4},
+			{Command: "FROM", Args: "--platform=linux/amd64 python:${PYTHON} AS base", Line: 6, EndLine: 6},
+			{Command: "RUN", Args: "apt-get update && apt-get install -y curl", Line: 7, EndLine: 11},
+			{Command: "COPY", Args: "<<EOF /etc/test.conf", Line: 12, EndLine: 14},
+			{Command: "FROM", Args: "base", Line: 15, EndLine: 15},
+		}, d.Instructions)
+	}
+}
+
+func (suite *DockerfileTestSuite) TestParseEscape() {
+	d, err := Parse("# escape=`\nFROM windows\nRUN dir `\n  c:\\\n")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), []Instruction{
+			{Command: "FROM", Args: "windows", Line: 2, EndLine: 2},
+			{Command: "RUN", Args: "dir c:\\", Line: 3, EndLine: 4},
+		}, d.Instructions)
+	}
+}
+
+func (suite *DockerfileTestSuite) TestParseDirectiveAfterComment() {
+	d, err := Parse("# Comment\n# syntax=docker/dockerfile:1\nFROM alpine\n")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), map[string]string{}, d.Directives)
+		assert.Equal(suite.T(), 1, len(d.Instructions))
+	}
+}
+
+func (suite *DockerfileTestSuite) TestParseErrors() {
+	for content, message := range map[string]string{
+		"FROM alpine\nRUN cat <<EOF\nfoo\n":          "line 2: heredoc EOF is not terminated",
+		"FROM alpine\n--mount=type=cache RUN true\n": "line 2: unknown instruction '--mount=type=cache'",
+		"# escape=x\nFROM alpine\n":                  "invalid escape directive 'x', must be \\ or `",
+		"# syntax=a\n# syntax=b\nFROM alpine\n":      "line 2: parser directive syntax set twice",
+	} {
+		_, err := Parse(content)
+		if assert.NotNil(suite.T(), err, content) {
+			assert.Equal(suite.T(), message, err.Error())
+		}
+	}
+}
+
+func (suite *DockerfileTestSuite) TestReplace() {
+	d, _ := Parse("# syntax=docker/dockerfile:1\nFROM \\\n  alpine\nRUN true\n")
+
+	d.Replace(d.Instructions[0], "FROM ${BASE}")
+	d.InsertFirst("ARG BASE")
+
+	assert.Equal(suite.T(), "# syntax=docker/dockerfile:1\nARG BASE\nFROM ${BASE}\n\nRUN true\n", d.String())
+}
+
+func (suite *DockerfileTestSuite) TestParseFrom() {
+	for args, expected := range map[string]From{
+		"alpine":  {Image: "alpine"},
+		"":        {},
+		"AS test": {Name: "test"},
+		"--platform=$BUILDPLATFORM golang as build": {Flags: []string{"--platform=$BUILDPLATFORM"}, Image: "golang", Name: "build"},
+	} {
+		from, err := ParseFrom(args)
+		if assert.Nil(suite.T(), err, args) {
+			assert.Equal(suite.T(), expected, from, args)
+		}
+	}
+
+	_, err := ParseFrom("alpine foo")
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "invalid FROM 'alpine foo', expected [--flag...] image [AS name]", err.Error())
+	}
+}
+
+func (suite *DockerfileTestSuite) TestFromString() {
+	assert.Equal(suite.T(), "FROM --platform=linux/amd64 ${BASE} AS test", From{Flags: []string{"--platform=linux/amd64"}, Image: "${BASE}", Name: "test"}.String())
+	assert.Equal(suite.T(), "FROM alpine", From{Image: "alpine"}.String())
+}
@@ -0,0 +1,62 @@
+// Package errdefs defines the errors returned by wrench packages, so callers
+// can tell failures apart with errors.As and errors.Is.
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Revision is already a release, there is nothing to bump
+var ErrAlreadyReleased = errors.New("revision already released")
+
+// Project has neither Dockerfile nor Dockerfile.builder
+var ErrNoDockerfile = errors.New("No Dockerfile found.")
+
+// Project config can't be loaded, is invalid or project values can't be
+// detected
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Project image needed by a command doesn't exist, project must be built
+type ImageNotFoundError struct {
+	Image string
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("Image %s does not exist, run wrench build", e.Image)
+}
+
+// External command like docker or git failed
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Output   string
+}
+
+func (e *CommandError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("%s exited with %d", e.Command, e.ExitCode)
+	}
+	return fmt.Sprintf("%s exited with %d: %s", e.Command, e.ExitCode, e.Output)
+}
+
+// Command run in a project image exited with non-zero exit code, its
+// output has already been written
+type RunError struct {
+	Name     string
+	ExitCode int
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%s failed with exit code %d", e.Name, e.ExitCode)
+}
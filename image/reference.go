@@ -0,0 +1,168 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Reference to docker image, [registry/]repository:tag
+type Reference struct {
+	// Repository including registry, registry.example.com:5000/org/name
+	Name string
+	Tag  string
+}
+
+const maxTagLength = 128
+
+// Grammar from the distribution spec
+var nameComponentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+var registryRegexp = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?$`)
+var tagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+var invalidNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+var validSeparatorRegexp = regexp.MustCompile(`^(?:[._]|__|-+)$`)
+var invalidTagRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Parse image reference and validate it, without normalising
+func Parse(s string) (Reference, error) {
+	if strings.Contains(s, "@") {
+		return Reference{}, fmt.Errorf("image reference '%s' with digest not supported", s)
+	}
+
+	ref := Reference{Name: s}
+
+	// Tag is after last colon not part of registry port
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		ref.Name = s[:i]
+		ref.Tag = s[i+1:]
+	}
+
+	if err := ref.Validate(); err != nil {
+		return Reference{}, err
+	}
+
+	return ref, nil
+}
+
+// Create normalised image reference from name and tag
+func New(name string, tag string) (Reference, error) {
+	ref := Reference{
+		Name: NormalizeName(name),
+		Tag:  NormalizeTag(tag),
+	}
+
+	if err := ref.Validate(); err != nil {
+		return Reference{}, err
+	}
+
+	return ref, nil
+}
+
+// Validate image reference against the distribution spec
+func (r Reference) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("image name empty")
+	}
+
+	registry, components := splitRegistry(r.Name)
+	if registry != "" && !registryRegexp.MatchString(registry) {
+		return fmt.Errorf("invalid registry '%s' in image name '%s'", registry, r.Name)
+	}
+	for _, component := range components {
+		if !nameComponentRegexp.MatchString(component) {
+			return fmt.Errorf("invalid image name '%s', must be lowercase letters, digits and separators", r.Name)
+		}
+	}
+
+	if r.Tag != "" && !tagRegexp.MatchString(r.Tag) {
+		return fmt.Errorf("invalid image tag '%s', must be at most %d letters, digits, '_', '.' and '-'", r.Tag, maxTagLength)
+	}
+
+	return nil
+}
+
+// Split registry from repository path components. First component is a
+// registry if it looks like a host name.
+func splitRegistry(name string) (string, []string) {
+	components := strings.Split(name, "/")
+	if len(components) > 1 {
+		first := components[0]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			return first, components[1:]
+		}
+	}
+	return "", components
+}
+
+// Normalise image name to lowercase with invalid characters replaced by
+// dashes. Registry is kept as is.
+func NormalizeName(name string) string {
+	registry, components := splitRegistry(strings.Trim(name, "/"))
+
+	var normalized []string
+	for _, component := range components {
+		component = strings.ToLower(component)
+
+		// Replace invalid separators, keep valid ones
+		component = invalidNameRegexp.ReplaceAllStringFunc(component, func(separator string) string {
+			if validSeparatorRegexp.MatchString(separator) {
+				return separator
+			}
+			return "-"
+		})
+		component = strings.Trim(component, "._-")
+
+		if component != "" {
+			normalized = append(normalized, component)
+		}
+	}
+
+	if registry != "" {
+		normalized = append([]string{registry}, normalized...)
+	}
+
+	return strings.Join(normalized, "/")
+}
+
+// Normalise tag by replacing invalid characters like / and + with dashes
+// and truncating it to max tag length
+func NormalizeTag(tag string) string {
+	tag = invalidTagRegexp.ReplaceAllString(tag, "-")
+	tag = strings.TrimLeft(tag, ".-")
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	return tag
+}
+
+func (r Reference) String() string {
+	if r.Tag == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s:%s", r.Name, r.Tag)
+}
+
+// Copy of reference with another normalised tag
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag = NormalizeTag(tag)
+	return r
+}
+
+// Copy of reference with suffix added to tag, like -test or -builder
+func (r Reference) WithTagSuffix(suffix string) Reference {
+	return r.WithTag(r.Tag + suffix)
+}
+
+// Registry of reference, empty for images on Docker Hub
+func (r Reference) Registry() string {
+	registry, _ := splitRegistry(r.Name)
+	return registry
+}
+
+// Copy of reference in another registry, replacing registry if set
+func (r Reference) WithRegistry(registry string) Reference {
+	_, components := splitRegistry(r.Name)
+	r.Name = strings.Join(append([]string{strings.Trim(registry, "/")}, components...), "/")
+	return r
+}
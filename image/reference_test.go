@@ -0,0 +1,127 @@
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReferenceTestSuite struct {
+	suite.Suite
+}
+
+func TestReferenceTestSuite(t *testing.T) {
+	suite.Run(t, new(ReferenceTestSuite))
+}
+
+func (suite *ReferenceTestSuite) TestParse() {
+	var examples = []struct {
+		Input    string
+		Expected Reference
+	}{
+		{"foobar", Reference{Name: "foobar"}},
+		{"example/foobar:v1.0.0", Reference{Name: "example/foobar", Tag: "v1.0.0"}},
+		{"localhost:5000/example/foobar", Reference{Name: "localhost:5000/example/foobar"}},
+		{"registry.example.com:5000/example/foobar:v1.0.0-1-gabc123", Reference{Name: "registry.example.com:5000/example/foobar", Tag: "v1.0.0-1-gabc123"}},
+		{"example/foo__bar.baz:latest", Reference{Name: "example/foo__bar.baz", Tag: "latest"}},
+	}
+
+	for _, ex := range examples {
+		ref, err := Parse(ex.Input)
+
+		if assert.Nil(suite.T(), err, ex.Input) {
+			assert.Equal(suite.T(), ex.Expected, ref)
+			assert.Equal(suite.T(), ex.Input, ref.String())
+		}
+	}
+}
+
+func (suite *ReferenceTestSuite) TestParseInvalid() {
+	var examples = []struct {
+		Input string
+		Error string
+	}{
+		{"", "image name empty"},
+		{"Example/foobar:v1", "invalid image name 'Example/foobar', must be lowercase letters, digits and separators"},
+		{"example/foobar:v1+build", "invalid image tag 'v1+build', must be at most 128 letters, digits, '_', '.' and '-'"},
+		{"example/foobar:.v1", "invalid image tag '.v1', must be at most 128 letters, digits, '_', '.' and '-'"},
+		{"example/foobar@sha256:abc", "image reference 'example/foobar@sha256:abc' with digest not supported"},
+	}
+
+	for _, ex := range examples {
+		_, err := Parse(ex.Input)
+
+		if assert.NotNil(suite.T(), err, ex.Input) {
+			assert.Equal(suite.T(), ex.Error, err.Error())
+		}
+	}
+}
+
+func (suite *ReferenceTestSuite) TestNormalizeName() {
+	var examples = []struct {
+		Input    string
+		Expected string
+	}{
+		{"example/foobar", "example/foobar"},
+		{"Example/FooBar", "example/foobar"},
+		{"example/foo bar", "example/foo-bar"},
+		{"example/foo..bar", "example/foo-bar"},
+		{"example/foo__bar", "example/foo__bar"},
+		{"example/-foobar_", "example/foobar"},
+		{"Registry.Example.com:5000/Example/foobar", "Registry.Example.com:5000/example/foobar"},
+	}
+
+	for _, ex := range examples {
+		assert.Equal(suite.T(), ex.Expected, NormalizeName(ex.Input))
+	}
+}
+
+func (suite *ReferenceTestSuite) TestNormalizeTag() {
+	var examples = []struct {
+		Input    string
+		Expected string
+	}{
+		{"v1.0.0", "v1.0.0"},
+		{"v1.2.0-feature/X.5.gabc123", "v1.2.0-feature-X.5.gabc123"},
+		{"1.2.1.dev5+gabc123", "1.2.1.dev5-gabc123"},
+		{"-latest", "latest"},
+		{strings.Repeat("a", 200), strings.Repeat("a", 128)},
+	}
+
+	for _, ex := range examples {
+		assert.Equal(suite.T(), ex.Expected, NormalizeTag(ex.Input))
+	}
+}
+
+func (suite *ReferenceTestSuite) TestNew() {
+	ref, err := New("Example/Foo Bar", "feature/foo")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "example/foo-bar:feature-foo", ref.String())
+	}
+}
+
+func (suite *ReferenceTestSuite) TestNewInvalid() {
+	_, err := New("!!!", "v1.0.0")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "image name empty", err.Error())
+	}
+}
+
+func (suite *ReferenceTestSuite) TestWith() {
+	ref := Reference{Name: "example/foobar", Tag: "v1.0.0"}
+
+	assert.Equal(suite.T(), "example/foobar:v1.0.0-test", ref.WithTagSuffix("-test").String())
+	assert.Equal(suite.T(), "example/foobar:main-latest", ref.WithTag("main-latest").String())
+	assert.Equal(suite.T(), "registry.example.com/example/foobar:v1.0.0", ref.WithRegistry("registry.example.com/").String())
+	assert.Equal(suite.T(), "localhost:5000/example/foobar:v1.0.0",
+		Reference{Name: "ghcr.io/example/foobar", Tag: "v1.0.0"}.WithRegistry("localhost:5000").String())
+}
+
+func (suite *ReferenceTestSuite) TestRegistry() {
+	assert.Equal(suite.T(), "", Reference{Name: "example/foobar"}.Registry())
+	assert.Equal(suite.T(), "registry.example.com:5000", Reference{Name: "registry.example.com:5000/example/foobar"}.Registry())
+}
@@ -0,0 +1,128 @@
+// Package log writes leveled messages of wrench to stderr, as text lines
+// like "INFO: message" or as JSON objects for CI ingestion. Results of
+// commands are written to stdout by the commands themselves.
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug:   "debug",
+	LevelInfo:    "info",
+	LevelWarning: "warning",
+	LevelError:   "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// Formats of log output
+var Formats = []string{"text", "json"}
+
+var mutex sync.Mutex
+var level = LevelInfo
+var format = "text"
+var output io.Writer = os.Stderr
+
+// Get current time, replaced in tests
+var now = time.Now
+
+// Only write messages of level and above
+func SetLevel(l Level) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	level = l
+}
+
+// Set output format, text or json
+func SetFormat(f string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, name := range Formats {
+		if f == name {
+			format = f
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Unknown log format %s, must be one of %s", f, strings.Join(Formats, ", ")))
+}
+
+func SetOutput(w io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	output = w
+}
+
+// Check if messages of level are written
+func Enabled(l Level) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return l >= level
+}
+
+func Debugf(format string, args ...interface{}) {
+	write(LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func Infof(format string, args ...interface{}) {
+	write(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func Warningf(format string, args ...interface{}) {
+	write(LevelWarning, fmt.Sprintf(format, args...))
+}
+
+func Errorf(format string, args ...interface{}) {
+	write(LevelError, fmt.Sprintf(format, args...))
+}
+
+type entry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+}
+
+func write(l Level, message string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if l < level {
+		return
+	}
+
+	message = strings.TrimSpace(message)
+
+	if format == "json" {
+		line, err := json.Marshal(entry{
+			Time:    now().UTC().Format(time.RFC3339),
+			Level:   l.String(),
+			Message: message,
+		})
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(output, string(line))
+		return
+	}
+
+	fmt.Fprintf(output, "%s: %s\n", strings.ToUpper(l.String()), message)
+}
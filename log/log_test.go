@@ -0,0 +1,78 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LogTestSuite struct {
+	suite.Suite
+	out *bytes.Buffer
+}
+
+func TestLogTestSuite(t *testing.T) {
+	suite.Run(t, new(LogTestSuite))
+}
+
+func (suite *LogTestSuite) SetupTest() {
+	suite.out = &bytes.Buffer{}
+	SetOutput(suite.out)
+	SetLevel(LevelInfo)
+	SetFormat("text")
+	now = func() time.Time {
+		return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+}
+
+func (suite *LogTestSuite) TearDownTest() {
+	SetOutput(os.Stderr)
+	now = time.Now
+}
+
+func (suite *LogTestSuite) TestText() {
+	Infof("Building %s\n\n", "acme/api:v1.0.0")
+	Warningf("Skipping tag %s", "foo")
+	Errorf("failed")
+
+	assert.Equal(suite.T(), "INFO: Building acme/api:v1.0.0\nWARNING: Skipping tag foo\nERROR: failed\n", suite.out.String())
+}
+
+func (suite *LogTestSuite) TestLevelDefault() {
+	Debugf("docker build")
+	assert.Equal(suite.T(), "", suite.out.String())
+}
+
+func (suite *LogTestSuite) TestLevelDebug() {
+	SetLevel(LevelDebug)
+	Debugf("docker build")
+	assert.Equal(suite.T(), "DEBUG: docker build\n", suite.out.String())
+	assert.True(suite.T(), Enabled(LevelDebug))
+}
+
+func (suite *LogTestSuite) TestLevelError() {
+	SetLevel(LevelError)
+	Infof("Building")
+	Warningf("Skipping")
+	Errorf("failed")
+	assert.Equal(suite.T(), "ERROR: failed\n", suite.out.String())
+	assert.False(suite.T(), Enabled(LevelWarning))
+}
+
+func (suite *LogTestSuite) TestJSON() {
+	assert.Nil(suite.T(), SetFormat("json"))
+	Warningf("unknown key %q", "project")
+
+	assert.Equal(suite.T(), `{"time":"2020-01-02T03:04:05Z","level":"warning","msg":"unknown key \"project\""}`+"\n", suite.out.String())
+}
+
+func (suite *LogTestSuite) TestFormatUnknown() {
+	err := SetFormat("xml")
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown log format xml, must be one of text, json", err.Error())
+	}
+}
@@ -1,68 +1,44 @@
 package push
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"regexp"
 
 	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/report"
 	"github.com/tomologic/wrench/utils"
-
-	"github.com/spf13/cobra"
 )
 
-func AddToWrench(rootCmd *cobra.Command) {
-	var flag_additional_tags string
-
-	var cmdBump = &cobra.Command{
-		Use:   "push [--additional-tags]",
-		Short: "Push project release image to docker registry",
-		Long:  `will push release image for current version to specified registry`,
-		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 1 {
-				if err := push(args[0], flag_additional_tags); err != nil {
-					fmt.Println(err)
-					os.Exit(1)
-				}
-			} else {
-				cmd.Usage()
-				os.Exit(1)
-			}
-		},
+// Push release image of project version to registry, tagged with the
+// tags from TagTemplates and additional tags. Pushed tags and their
+// digests are added to push_report if set.
+func Push(ctx context.Context, c *config.Resolved, registry string, additional_tags []string, push_report *report.Report) error {
+	tags, err := c.GetImageTags(c.GetProjectVersion())
+	if err != nil {
+		return err
 	}
-
-	cmdBump.Flags().StringVar(&flag_additional_tags, "additional-tags", "", "Comma separated list of additional tags to push 'latest,prod'")
-
-	rootCmd.AddCommand(cmdBump)
-}
-
-func push(registry string, additional_tags string) error {
-	tags := strings.Split(additional_tags, ",")
-	tags = append(tags, config.GetProjectVersion())
+	tags = append(tags, additional_tags...)
 	tags = utils.RemoveEmptyStrings(tags)
 
-	for _, tag := range tags {
-		image_name := fmt.Sprintf("%s/%s:%s",
-			config.GetProjectOrganization(),
-			config.GetProjectName(),
-			config.GetProjectVersion())
-
-		registry = strings.Trim(registry, "/")
-
-		tmp_image_name := fmt.Sprintf("%s/%s/%s:%s",
-			registry,
-			config.GetProjectOrganization(),
-			config.GetProjectName(),
-			tag)
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return err
+	}
+	image_name := ref.String()
 
+	for _, tag := range tags {
 		// prefix image name with registry
-		if err := tag_image(image_name, tmp_image_name); err != nil {
+		tmp_image_name := ref.WithRegistry(registry).WithTag(tag).String()
+
+		if err := tag_image(ctx, image_name, tmp_image_name); err != nil {
 			return err
 		}
 
 		// push prefixed image
-		push_err := push_image(tmp_image_name)
+		digest, push_err := push_image(ctx, tmp_image_name)
 
 		// cleanup prefixed images
 		cleanup_err := remove_image(tmp_image_name)
@@ -74,18 +50,20 @@ func push(registry string, additional_tags string) error {
 		if cleanup_err != nil {
 			return cleanup_err
 		}
+
+		push_report.AddPush(report.Push{Name: tmp_image_name, Digest: digest})
 	}
 
 	return nil
 }
 
-func tag_image(image_name string, new_image_name string) error {
+func tag_image(ctx context.Context, image_name string, new_image_name string) error {
 	command := fmt.Sprintf(
 		"docker tag %s %s",
 		image_name,
 		new_image_name)
 
-	exitcode, out := utils.RunCmd(command)
+	exitcode, out := utils.RunCmdContext(ctx, command)
 	if exitcode != 0 {
 		fmt.Fprintln(os.Stderr, out)
 
@@ -98,18 +76,30 @@ func tag_image(image_name string, new_image_name string) error {
 	return nil
 }
 
-func push_image(image string) error {
+// Push image and return its digest from output of docker push
+func push_image(ctx context.Context, image string) (string, error) {
 	command := fmt.Sprintf(
 		"docker push %s", image)
 
-	exitcode, out := utils.RunCmd(command)
+	exitcode, out := utils.RunCmdContext(ctx, command)
 	if exitcode != 0 {
 		fmt.Fprintln(os.Stderr, out)
 
-		return errors.New(fmt.Sprintf("Could not push %s", image))
+		return "", errors.New(fmt.Sprintf("Could not push %s", image))
 	}
 
-	return nil
+	return get_push_digest(out), nil
+}
+
+var push_digest_regexp = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
+// Get digest from output of docker push, empty if not found
+func get_push_digest(out string) string {
+	match := push_digest_regexp.FindStringSubmatch(out)
+	if match == nil {
+		return ""
+	}
+	return match[1]
 }
 
 func remove_image(image string) error {
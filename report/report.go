@@ -0,0 +1,214 @@
+// Package report records results of wrench commands, the images built,
+// the tags pushed and the commands run, so CI pipelines can consume exact
+// image IDs and digests instead of scraping output.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats of report files, junit only contains run commands
+var Formats = []string{"json", "junit"}
+
+// Image built, or found already built, by wrench build
+type Image struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+
+	// Repository digest, only set if image was pulled or pushed
+	Digest string `json:"digest,omitempty"`
+
+	// Size in bytes
+	Size int64 `json:"size"`
+
+	// False if image already existed and was not rebuilt
+	Built bool `json:"built"`
+
+	// Duration of build in seconds
+	Duration float64 `json:"duration"`
+
+	// Number of build steps taken from the build cache
+	CacheHits int `json:"cache_hits"`
+}
+
+// Tag pushed by wrench push
+type Push struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// Command run by wrench run
+type Run struct {
+	Name string `json:"name"`
+
+	// ok, failed or skipped
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+
+	// Duration of command in seconds
+	Duration float64 `json:"duration"`
+}
+
+// Report of a wrench command. Methods of a nil report do nothing, so
+// results can be recorded without checking if a report was requested.
+type Report struct {
+	mutex sync.Mutex
+
+	// Command reported, like "build" or "run test"
+	Command string `json:"command"`
+
+	Images []Image `json:"images"`
+	Pushed []Push  `json:"pushed"`
+	Runs   []Run   `json:"runs"`
+}
+
+func New(command string) *Report {
+	return &Report{
+		Command: command,
+		Images:  []Image{},
+		Pushed:  []Push{},
+		Runs:    []Run{},
+	}
+}
+
+func (r *Report) AddImage(image Image) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Images = append(r.Images, image)
+}
+
+func (r *Report) AddPush(push Push) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Pushed = append(r.Pushed, push)
+}
+
+func (r *Report) AddRun(run Run) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Runs = append(r.Runs, run)
+}
+
+// Get duration in seconds, rounded to milliseconds
+func Seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// Check that format is one of Formats
+func CheckFormat(format string) error {
+	for _, name := range Formats {
+		if format == name {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Unknown report format %s, must be one of %s", format, strings.Join(Formats, ", ")))
+}
+
+// Get report as indented JSON
+func (r *Report) JSON() ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return json.MarshalIndent(r, "", "  ")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// Get run commands of report as JUnit XML, one test case per command
+func (r *Report) JUnit() ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	suite := junitTestSuite{Name: fmt.Sprintf("wrench %s", r.Command)}
+
+	total := 0.0
+	for _, run := range r.Runs {
+		test := junitTestCase{
+			Name:      run.Name,
+			ClassName: "wrench.run",
+			Time:      fmt.Sprintf("%.3f", run.Duration),
+		}
+
+		switch run.Status {
+		case "failed":
+			test.Failure = &junitMessage{Message: fmt.Sprintf("%s failed with exit code %d", run.Name, run.ExitCode)}
+			suite.Failures += 1
+		case "skipped":
+			test.Skipped = &junitMessage{Message: "dependency failed or run stopped after failure"}
+			suite.Skipped += 1
+		}
+
+		suite.Cases = append(suite.Cases, test)
+		suite.Tests += 1
+		total += run.Duration
+	}
+	suite.Time = fmt.Sprintf("%.3f", total)
+
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// Write report to file in format json or junit
+func (r *Report) WriteFile(file string, format string) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	var out []byte
+	var err error
+	if format == "junit" {
+		out, err = r.JUnit()
+	} else {
+		out, err = r.JSON()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(file, append(out, '\n'), 0644); err != nil {
+		return errors.New(fmt.Sprintf("Unable to write report %s: %s", file, err))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReportTestSuite struct {
+	suite.Suite
+}
+
+func TestReportTestSuite(t *testing.T) {
+	suite.Run(t, new(ReportTestSuite))
+}
+
+func (suite *ReportTestSuite) TestJSON() {
+	r := New("push")
+	r.AddPush(Push{Name: "registry.acme.com/acme/api:v1.0.0", Digest: "sha256:1234"})
+
+	out, err := r.JSON()
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), `{
+  "command": "push",
+  "images": [],
+  "pushed": [
+    {
+      "name": "registry.acme.com/acme/api:v1.0.0",
+      "digest": "sha256:1234"
+    }
+  ],
+  "runs": []
+}`, string(out))
+	}
+}
+
+func (suite *ReportTestSuite) TestNil() {
+	var r *Report
+	r.AddImage(Image{Name: "acme/api:v1.0.0"})
+	r.AddPush(Push{Name: "acme/api:v1.0.0"})
+	r.AddRun(Run{Name: "test"})
+}
+
+func (suite *ReportTestSuite) TestJUnit() {
+	r := New("run ci")
+	r.AddRun(Run{Name: "unit", Status: "ok", Duration: 1.5})
+	r.AddRun(Run{Name: "integration", Status: "failed", ExitCode: 2, Duration: 0.25})
+	r.AddRun(Run{Name: "deploy", Status: "skipped"})
+
+	out, err := r.JUnit()
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="wrench run ci" tests="3" failures="1" skipped="1" time="1.750">
+    <testcase name="unit" classname="wrench.run" time="1.500"></testcase>
+    <testcase name="integration" classname="wrench.run" time="0.250">
+      <failure message="integration failed with exit code 2"></failure>
+    </testcase>
+    <testcase name="deploy" classname="wrench.run" time="0.000">
+      <skipped message="dependency failed or run stopped after failure"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>`, string(out))
+	}
+}
+
+func (suite *ReportTestSuite) TestWriteFile() {
+	file := filepath.Join(suite.T().TempDir(), "report.json")
+
+	r := New("build")
+	r.AddImage(Image{Name: "acme/api:v1.0.0", ID: "sha256:abcd", Size: 1024, Built: true, Duration: Seconds(1500 * time.Millisecond), CacheHits: 2})
+
+	if assert.Nil(suite.T(), r.WriteFile(file, "json")) {
+		content, _ := ioutil.ReadFile(file)
+		assert.Contains(suite.T(), string(content), `"duration": 1.5,`)
+		assert.Contains(suite.T(), string(content), `"cache_hits": 2`)
+	}
+}
+
+func (suite *ReportTestSuite) TestWriteFileUnknownFormat() {
+	err := New("build").WriteFile(filepath.Join(suite.T().TempDir(), "report.xml"), "xml")
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown report format xml, must be one of json, junit", err.Error())
+	}
+}
@@ -0,0 +1,36 @@
+package run
+
+import (
+	"sync"
+)
+
+// Cleanup collects functions that remove resources created by a run
+// (containers, networks, images, tempdirs). They are executed in reverse
+// order when the run returns, also when it returns because its context
+// was cancelled by an interrupt. Functions must not use the context of
+// the run.
+type cleanup struct {
+	mutex sync.Mutex
+	funcs []func()
+}
+
+func newCleanup() *cleanup {
+	return &cleanup{}
+}
+
+func (c *cleanup) Add(f func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.funcs = append(c.funcs, f)
+}
+
+func (c *cleanup) Run() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i := len(c.funcs) - 1; i >= 0; i-- {
+		c.funcs[i]()
+	}
+	c.funcs = nil
+}
@@ -0,0 +1,73 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/config"
+)
+
+type ParamsTestSuite struct {
+	suite.Suite
+}
+
+func TestParamsTestSuite(t *testing.T) {
+	suite.Run(t, new(ParamsTestSuite))
+}
+
+func paramsRun() config.Run {
+	pattern := "./..."
+	return config.Run{
+		Cmd: "go test $PATTERN -run $TEST",
+		Params: map[string]*string{
+			"PATTERN": &pattern,
+			"TEST":    nil,
+		},
+	}
+}
+
+func (suite *ParamsTestSuite) TestParseParams() {
+	params, err := ParseParams([]string{"FOO=bar", "EMPTY=", "EQ=a=b"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]string{"FOO": "bar", "EMPTY": "", "EQ": "a=b"}, params)
+}
+
+func (suite *ParamsTestSuite) TestParseParamsInvalid() {
+	_, err := ParseParams([]string{"FOO"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unable to parse param 'FOO', expected name=value", err.Error())
+	}
+}
+
+func (suite *ParamsTestSuite) TestGetParamsEnv() {
+	env, err := getParamsEnv("unit", paramsRun(), map[string]string{"TEST": "TestFoo"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"PATTERN=./...", "TEST=TestFoo"}, env)
+}
+
+func (suite *ParamsTestSuite) TestGetParamsEnvOverrideDefault() {
+	env, err := getParamsEnv("unit", paramsRun(), map[string]string{"TEST": "TestFoo", "PATTERN": "./config"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"PATTERN=./config", "TEST=TestFoo"}, env)
+}
+
+func (suite *ParamsTestSuite) TestGetParamsEnvMissingRequired() {
+	_, err := getParamsEnv("unit", paramsRun(), map[string]string{})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Missing required param TEST for unit", err.Error())
+	}
+}
+
+func (suite *ParamsTestSuite) TestGetParamsEnvUnknown() {
+	_, err := getParamsEnv("unit", paramsRun(), map[string]string{"TEST": "TestFoo", "FOO": "bar"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown param FOO for unit", err.Error())
+	}
+}
@@ -0,0 +1,262 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/secrets"
+	"github.com/tomologic/wrench/utils"
+)
+
+const (
+	statusOk      = "ok"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+type stepResult struct {
+	Name     string
+	Status   string
+	ExitCode int
+	Duration time.Duration
+	Err      error
+}
+
+// Get run item and all run items it depends on. Params are checked for
+// every step before any step is run.
+func getPipelineSteps(c *config.Resolved, name string, params map[string]string) (map[string]config.Run, error) {
+	steps := make(map[string]config.Run)
+
+	var add func(name string)
+	add = func(name string) {
+		if _, ok := steps[name]; ok {
+			return
+		}
+		run, _ := c.GetRun(name)
+		steps[name] = run
+		for _, dep := range run.DependsOn {
+			add(dep)
+		}
+	}
+	add(name)
+
+	if err := checkPipelineParams(steps, name, params); err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+// Check that every param is declared by a step and that every required
+// param of all steps is set. All missing params are reported at once.
+func checkPipelineParams(steps map[string]config.Run, target string, params map[string]string) error {
+	var names []string
+	for name := range steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var unknown []string
+	for param := range params {
+		declared := false
+		for _, name := range names {
+			if _, ok := steps[name].Params[param]; ok {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			unknown = append(unknown, param)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.New(fmt.Sprintf("Unknown param %s for %s", strings.Join(unknown, ", "), target))
+	}
+
+	var missing []string
+	for _, name := range names {
+		var step_missing []string
+		for param, value := range steps[name].Params {
+			if _, ok := params[param]; !ok && value == nil {
+				step_missing = append(step_missing, param)
+			}
+		}
+		sort.Strings(step_missing)
+		for _, param := range step_missing {
+			missing = append(missing, fmt.Sprintf("%s for %s", param, name))
+		}
+	}
+	if len(missing) == 1 {
+		return errors.New(fmt.Sprintf("Missing required param %s", missing[0]))
+	} else if len(missing) > 1 {
+		return errors.New(fmt.Sprintf("Missing required params %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// Get params declared by step
+func getStepParams(run config.Run, params map[string]string) map[string]string {
+	step_params := make(map[string]string)
+	for param, value := range params {
+		if _, ok := run.Params[param]; ok {
+			step_params[param] = value
+		}
+	}
+	return step_params
+}
+
+// Run steps in topological order. Steps that don't depend on each other
+// are run in parallel, at most jobs at a time. Unless keep_going is set no
+// new steps are started after a failure. Args are only used for the target
+// step, params for every step declaring them.
+func runPipeline(ctx context.Context, c *config.Resolved, steps map[string]config.Run, target string, options runOptions, jobs int, keep_going bool) []stepResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make(map[string]*stepResult)
+	var order []string
+
+	done := make(chan stepResult)
+	running := 0
+	failed := false
+
+	for len(results) < len(steps) {
+		// Start every step that is ready until jobs limit is reached
+		for _, step := range getReadySteps(steps, results) {
+			if running >= jobs {
+				break
+			}
+
+			// No new steps are started once wrench is interrupted
+			if (failed && !keep_going) || ctx.Err() != nil {
+				results[step] = &stepResult{Name: step, Status: statusSkipped}
+				order = append(order, step)
+				continue
+			}
+
+			if status := getDependencyStatus(steps[step], results); status != statusOk {
+				results[step] = &stepResult{Name: step, Status: statusSkipped}
+				order = append(order, step)
+				continue
+			}
+
+			results[step] = &stepResult{Name: step}
+			running += 1
+
+			go func(step string) {
+				step_options := runOptions{Params: getStepParams(steps[step], options.Params)}
+				if step == target {
+					step_options.Args = options.Args
+				}
+				done <- runStep(ctx, c, step, steps[step], step_options)
+			}(step)
+		}
+
+		if running == 0 {
+			continue
+		}
+
+		result := <-done
+		running -= 1
+
+		results[result.Name] = &result
+		order = append(order, result.Name)
+
+		if result.Status == statusFailed {
+			failed = true
+		}
+	}
+
+	list := make([]stepResult, len(order))
+	for i, step := range order {
+		list[i] = *results[step]
+	}
+	return list
+}
+
+// Get steps, sorted by name, not yet started whose dependencies are all done
+func getReadySteps(steps map[string]config.Run, results map[string]*stepResult) []string {
+	var ready []string
+
+	for step, run := range steps {
+		if _, ok := results[step]; ok {
+			continue
+		}
+
+		if getDependencyStatus(run, results) != "" {
+			ready = append(ready, step)
+		}
+	}
+	sort.Strings(ready)
+
+	return ready
+}
+
+// Get combined status of dependencies, empty string if not all done yet
+func getDependencyStatus(run config.Run, results map[string]*stepResult) string {
+	status := statusOk
+
+	for _, dep := range run.DependsOn {
+		result, ok := results[dep]
+		if !ok || result.Status == "" {
+			return ""
+		}
+		if result.Status != statusOk {
+			status = statusSkipped
+		}
+	}
+
+	return status
+}
+
+func runStep(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) stepResult {
+	result := stepResult{Name: name, Status: statusOk}
+
+	// Steps only grouping other steps have nothing to run
+	if run.Cmd == "" {
+		return result
+	}
+
+	start := time.Now()
+	err := runCommand(ctx, c, name, run, options)
+	result.Duration = time.Since(start)
+
+	if err != nil {
+		result.Status = statusFailed
+		result.Err = err
+		result.ExitCode = utils.GetCommandExitCode(err)
+		if result.ExitCode == 0 {
+			result.ExitCode = 1
+		}
+		log.Errorf("%s failed: %s", name, secrets.Mask(err.Error()))
+	}
+
+	return result
+}
+
+func printSummary(results []stepResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(w, "\nSTEP\tSTATUS\tEXIT CODE\tDURATION")
+	for _, result := range results {
+		exitcode := "-"
+		duration := "-"
+		if result.Status != statusSkipped {
+			exitcode = fmt.Sprintf("%d", result.ExitCode)
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Name, result.Status, exitcode, duration)
+	}
+	w.Flush()
+}
@@ -0,0 +1,197 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/config"
+)
+
+type PipelineTestSuite struct {
+	suite.Suite
+}
+
+func TestPipelineTestSuite(t *testing.T) {
+	suite.Run(t, new(PipelineTestSuite))
+}
+
+var pipelineSteps = map[string]config.Run{
+	"lint":        {Cmd: "lint"},
+	"unit":        {Cmd: "unit"},
+	"integration": {Cmd: "integration", DependsOn: []string{"unit"}},
+	"ci":          {DependsOn: []string{"lint", "integration"}},
+}
+
+func (suite *PipelineTestSuite) TearDownTest() {
+	runCommand = mocked_functions["runCommand"].(func(context.Context, *config.Resolved, string, config.Run, runOptions) error)
+}
+
+func mockRunCommand(failing string) *[]string {
+	var mutex sync.Mutex
+	var executed []string
+
+	runCommand = func(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) error {
+		mutex.Lock()
+		executed = append(executed, name)
+		mutex.Unlock()
+
+		if name == failing {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	return &executed
+}
+
+func getStatuses(results []stepResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, result := range results {
+		statuses[result.Name] = result.Status
+	}
+	return statuses
+}
+
+func (suite *PipelineTestSuite) TestPipelineOrder() {
+	executed := mockRunCommand("")
+
+	results := runPipeline(context.Background(), nil, pipelineSteps, "ci", runOptions{}, 1, false)
+
+	assert.Equal(suite.T(), []string{"lint", "unit", "integration"}, *executed)
+	assert.Equal(suite.T(), "ci", results[len(results)-1].Name)
+	for _, result := range results {
+		assert.Equal(suite.T(), statusOk, result.Status)
+	}
+}
+
+func (suite *PipelineTestSuite) TestPipelineParallel() {
+	executed := mockRunCommand("")
+
+	results := runPipeline(context.Background(), nil, pipelineSteps, "ci", runOptions{}, 4, false)
+
+	assert.Len(suite.T(), *executed, 3)
+	assert.Len(suite.T(), results, 4)
+	assert.Equal(suite.T(), "ci", results[len(results)-1].Name)
+}
+
+func (suite *PipelineTestSuite) TestPipelineFailFast() {
+	executed := mockRunCommand("lint")
+
+	results := runPipeline(context.Background(), nil, pipelineSteps, "ci", runOptions{}, 1, false)
+
+	assert.Equal(suite.T(), []string{"lint"}, *executed)
+	assert.Equal(suite.T(), map[string]string{
+		"lint":        statusFailed,
+		"unit":        statusSkipped,
+		"integration": statusSkipped,
+		"ci":          statusSkipped,
+	}, getStatuses(results))
+	assert.Equal(suite.T(), 1, results[0].ExitCode)
+}
+
+func (suite *PipelineTestSuite) TestPipelineKeepGoing() {
+	executed := mockRunCommand("unit")
+
+	results := runPipeline(context.Background(), nil, pipelineSteps, "ci", runOptions{}, 1, true)
+
+	assert.Equal(suite.T(), []string{"lint", "unit"}, *executed)
+	assert.Equal(suite.T(), map[string]string{
+		"lint":        statusOk,
+		"unit":        statusFailed,
+		"integration": statusSkipped,
+		"ci":          statusSkipped,
+	}, getStatuses(results))
+}
+
+func (suite *PipelineTestSuite) TestPipelineInterrupted() {
+	var executed []string
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Interrupted while first step runs
+	runCommand = func(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) error {
+		executed = append(executed, name)
+		cancel()
+		return ctx.Err()
+	}
+
+	results := runPipeline(ctx, nil, pipelineSteps, "ci", runOptions{}, 1, true)
+
+	assert.Equal(suite.T(), []string{"lint"}, executed)
+	assert.Equal(suite.T(), map[string]string{
+		"lint":        statusFailed,
+		"unit":        statusSkipped,
+		"integration": statusSkipped,
+		"ci":          statusSkipped,
+	}, getStatuses(results))
+}
+
+func (suite *PipelineTestSuite) TestPipelineOptionsOnlyForTarget() {
+	var mutex sync.Mutex
+	args := make(map[string][]string)
+
+	runCommand = func(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) error {
+		mutex.Lock()
+		args[name] = options.Args
+		mutex.Unlock()
+		return nil
+	}
+
+	runPipeline(context.Background(), nil, pipelineSteps, "integration", runOptions{Args: []string{"-v"}}, 1, false)
+
+	assert.Equal(suite.T(), map[string][]string{
+		"lint":        nil,
+		"unit":        nil,
+		"integration": {"-v"},
+	}, args)
+}
+
+func paramsSteps() map[string]config.Run {
+	pattern := "./..."
+	return map[string]config.Run{
+		"unit":   {Cmd: "unit", Params: map[string]*string{"PATTERN": &pattern, "TEST": nil}},
+		"deploy": {Cmd: "deploy", DependsOn: []string{"unit"}, Params: map[string]*string{"ENV": nil}},
+	}
+}
+
+func (suite *PipelineTestSuite) TestCheckPipelineParams() {
+	assert.Nil(suite.T(), checkPipelineParams(paramsSteps(), "deploy", map[string]string{"TEST": "TestFoo", "ENV": "prod"}))
+}
+
+func (suite *PipelineTestSuite) TestCheckPipelineParamsMissing() {
+	err := checkPipelineParams(paramsSteps(), "deploy", map[string]string{"PATTERN": "./config"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Missing required params ENV for deploy, TEST for unit", err.Error())
+	}
+}
+
+func (suite *PipelineTestSuite) TestCheckPipelineParamsUnknown() {
+	err := checkPipelineParams(paramsSteps(), "unit", map[string]string{"TEST": "TestFoo", "FOO": "bar"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Unknown param FOO for unit", err.Error())
+	}
+}
+
+func (suite *PipelineTestSuite) TestPipelineParamsForDependencies() {
+	var mutex sync.Mutex
+	params := make(map[string]map[string]string)
+
+	runCommand = func(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) error {
+		mutex.Lock()
+		params[name] = options.Params
+		mutex.Unlock()
+		return nil
+	}
+
+	runPipeline(context.Background(), nil, paramsSteps(), "deploy", runOptions{Params: map[string]string{"TEST": "TestFoo", "ENV": "prod"}}, 1, false)
+
+	assert.Equal(suite.T(), map[string]map[string]string{
+		"unit":   {"TEST": "TestFoo"},
+		"deploy": {"ENV": "prod"},
+	}, params)
+}
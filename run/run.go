@@ -1,72 +1,181 @@
 package run
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/spf13/cobra"
 	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/errdefs"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/report"
+	"github.com/tomologic/wrench/secrets"
 	"github.com/tomologic/wrench/utils"
 )
 
-var run_list *map[string]interface{}
-
-func AddToWrench(cmdRoot *cobra.Command) {
-	var cmdRun = &cobra.Command{
-		Use:   "run [command]",
-		Short: "Run commands in docker image",
-		Long:  `Run defined commands from wrench.yml inside application image`,
-		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) != 1 {
-				cmd.Usage()
-				os.Exit(1)
-			}
+// Options for a single run command provided on the command line
+type runOptions struct {
+	Args   []string
+	Params map[string]string
+}
+
+// Options of a run. Args are only used for the named command, Params for
+// the named command and the commands it depends on that declare them.
+type Options struct {
+	Args      []string
+	Params    map[string]string
+	Jobs      int
+	KeepGoing bool
+
+	// Exit code and duration of every command are added to report if set
+	Report *report.Report
+}
+
+// Run command from wrench.yml in project image, after the commands it
+// depends on. A summary is printed when more than one command was run.
+// Returns a RunError for the first command that failed.
+func Run(ctx context.Context, c *config.Resolved, name string, options Options) error {
+	if _, ok := c.GetRun(name); !ok {
+		return &errdefs.ConfigError{Err: errors.New(fmt.Sprintf("%s not found in wrench.yml", name))}
+	}
+
+	steps, err := getPipelineSteps(c, name, options.Params)
+	if err != nil {
+		return err
+	}
+
+	step_options := runOptions{Args: options.Args, Params: options.Params}
+	results := runPipeline(ctx, c, steps, name, step_options, options.Jobs, options.KeepGoing)
+	if len(results) > 1 {
+		printSummary(results)
+	}
 
-			run(args[0])
-		},
+	for _, result := range results {
+		options.Report.AddRun(report.Run{
+			Name:     result.Name,
+			Status:   result.Status,
+			ExitCode: result.ExitCode,
+			Duration: report.Seconds(result.Duration),
+		})
 	}
 
-	cmdRoot.AddCommand(cmdRun)
+	for _, result := range results {
+		if result.Status == statusFailed {
+			return &errdefs.RunError{Name: result.Name, ExitCode: result.ExitCode}
+		}
+	}
+
+	return nil
 }
 
-func run(name string) {
-	image_name := config.GetProjectImage()
+// Parse list of name=value into map
+func ParseParams(list []string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, item := range list {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return params, errors.New(fmt.Sprintf("Unable to parse param '%s', expected name=value", item))
+		}
+		params[parts[0]] = parts[1]
+	}
+	return params, nil
+}
 
-	run, ok := config.GetRun(name)
-	if ok == false {
-		fmt.Printf("ERROR: %s not found in wrench.yml\n", name)
-		os.Exit(1)
+// Get env variables for all params of run command. Params provided on the
+// command line override defaults, params without default are required.
+func getParamsEnv(name string, run config.Run, params map[string]string) ([]string, error) {
+	var env []string
+
+	for param := range params {
+		if _, ok := run.Params[param]; !ok {
+			return env, errors.New(fmt.Sprintf("Unknown param %s for %s", param, name))
+		}
 	}
 
-	if utils.FileExists("./Dockerfile.test") {
-		// If test dockerfile exists then use test image
-		image_name = fmt.Sprintf("%s-test", image_name)
+	var names []string
+	for param := range run.Params {
+		names = append(names, param)
 	}
+	sort.Strings(names)
 
-	if !utils.DockerImageExists(image_name) {
-		fmt.Printf("ERROR: Image %s does not exist, run wrench build\n", image_name)
-		os.Exit(1)
+	for _, param := range names {
+		value, ok := params[param]
+		if !ok {
+			if run.Params[param] == nil {
+				return env, errors.New(fmt.Sprintf("Missing required param %s for %s", param, name))
+			}
+			value = *run.Params[param]
+		}
+		env = append(env, fmt.Sprintf("%s=%s", param, value))
 	}
 
-	fmt.Printf("INFO: running %s in image %s\n", name, image_name)
+	return env, nil
+}
 
-	// Tempdir for building temporary run image
-	dir, err := os.Getwd()
+// Get name of project image to run in, test, builder or final
+func getRunImageName(c *config.Resolved, image string) (string, error) {
+	ref, err := c.GetProjectImageReference()
+	if err != nil {
+		return "", err
+	}
+
+	switch image {
+	case "test":
+		ref = ref.WithTagSuffix("-test")
+	case "builder":
+		ref = ref.WithTagSuffix("-builder")
+	case "final":
+	default:
+		if utils.FileExists(filepath.Join(c.Dir(), "Dockerfile.test")) {
+			// If test dockerfile exists then use test image
+			ref = ref.WithTagSuffix("-test")
+		}
+	}
+	image_name := ref.String()
+
+	if exists, err := utils.DockerImageExists(image_name); err != nil {
+		return image_name, err
+	} else if !exists {
+		return image_name, &errdefs.ImageNotFoundError{Image: image_name}
+	}
+
+	return image_name, nil
+}
+
+var runCommand = func(ctx context.Context, c *config.Resolved, name string, run config.Run, options runOptions) error {
+	params_env, err := getParamsEnv(name, run, options.Params)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err)
-		os.Exit(1)
+		return err
 	}
 
+	image_name, err := getRunImageName(c, run.Image)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("running %s in image %s", name, image_name)
+
+	// Tempdir for building temporary run image
+	dir := c.Dir()
+
 	tempdir, err := ioutil.TempDir(dir, ".wrench_run_")
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
 
+	// Cleanup is done on return and if wrench is interrupted
+	cleanup := newCleanup()
+	defer cleanup.Run()
+
+	cleanup.Add(func() {
+		os.RemoveAll(tempdir)
+	})
+
 	// Create temp Dockerfile
 	dockerfile_content := "" +
 		fmt.Sprintf("FROM %s\n", image_name) +
@@ -75,51 +184,159 @@ func run(name string) {
 		"CMD [\"/tmp/wrench_run.sh\"]\n"
 
 	dockerfile := fmt.Sprintf("%s/Dockerfile", tempdir)
-	utils.WriteFileContent(dockerfile, dockerfile_content)
+	if err := utils.WriteFileContent(dockerfile, dockerfile_content); err != nil {
+		return err
+	}
 
 	// Create wrench run shell script
 	runfile := fmt.Sprintf("%s/wrench_run.sh", tempdir)
-	utils.WriteFileContent(runfile, run.Cmd)
+	if err := utils.WriteFileContent(runfile, run.Cmd); err != nil {
+		return err
+	}
 
 	tempdir_base := string(filepath.Base(tempdir))
 	run_image_name := fmt.Sprintf("%s-%s", image_name, tempdir_base)
 
+	// Name used for run container and services network
+	run_name := strings.TrimLeft(tempdir_base, ".")
+
+	// Image may be created even if build is interrupted
+	cleanup.Add(func() {
+		utils.DockerRemoveImage(run_image_name)
+	})
+
 	cmd_string := fmt.Sprintf("docker build -t '%s' .", run_image_name)
-	cmd := exec.Command("sh", "-c", cmd_string)
+	cmd := utils.ShellCommandContext(ctx, cmd_string)
 	cmd.Dir = tempdir
 	out, err := cmd.Output()
-
-	// Remove tempdir
 	if err != nil {
-		os.RemoveAll(tempdir)
+		return errors.New(string(out))
+	}
 
-		fmt.Printf("ERROR: %s\n", string(out))
-		os.Exit(1)
+	docker_args := []string{"run", "--rm", "--name", run_name}
+	if utils.IsTerminal(os.Stdout) {
+		docker_args = append(docker_args, "-t")
 	}
 
-	// Defer so all defered cleanup is done
-	defer func() {
+	env_args, env, err := getDockerEnv(c, run)
+	if err != nil {
+		return err
+	}
+	docker_args = append(docker_args, env_args...)
+
+	if len(run.Services) > 0 {
+		service_env, err := startServices(ctx, run.Services, run_name, cleanup)
 		if err != nil {
-			fmt.Printf("ERROR: %s\n", err)
-			os.Exit(utils.GetCommandExitCode(err))
+			return err
 		}
-	}()
-	defer utils.DockerRemoveImage(run_image_name)
-	defer os.RemoveAll(tempdir)
 
-	if len(run.Env) > 0 {
-		envfile := fmt.Sprintf("./%s-env", tempdir_base)
-		utils.WriteFileContent(envfile, strings.Join(run.Env, "\n"))
-		defer os.Remove(envfile)
+		docker_args = append(docker_args, "--network", run_name)
+		for _, e := range service_env {
+			docker_args = append(docker_args, "-e", e)
+		}
+	}
 
-		cmd_string = fmt.Sprintf("docker run -t --rm --env-file '%s' '%s'", envfile, run_image_name)
-	} else {
-		cmd_string = fmt.Sprintf("docker run -t --rm '%s'", run_image_name)
+	for _, e := range params_env {
+		docker_args = append(docker_args, "-e", e)
 	}
 
+	docker_args = append(docker_args, getDockerRunArgs(run, dir)...)
+	docker_args = append(docker_args, getDockerCommandArgs(run, run_image_name, options.Args)...)
+
+	// Make sure the run container is stopped if wrench is interrupted
+	cleanup.Add(func() {
+		utils.RunCmd(fmt.Sprintf("docker rm -f '%s'", run_name))
+	})
+
 	// Run
-	cmd = exec.Command("sh", "-c", cmd_string)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
+	log.Debugf("docker %s", secrets.Mask(strings.Join(docker_args, " ")))
+	cmd = utils.CommandContext(ctx, "docker", docker_args...)
+	cmd.Env = env
+	if len(run.Secrets) > 0 {
+		stdout := secrets.NewMaskWriter(os.Stdout)
+		stderr := secrets.NewMaskWriter(os.Stderr)
+		defer stdout.Flush()
+		defer stderr.Flush()
+
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	return cmd.Run()
+}
+
+// Get docker run arguments and docker client environment for env variables
+// and secrets. Secret values are only passed through the environment of the
+// docker client, never as arguments or files.
+func getDockerEnv(c *config.Resolved, run config.Run) ([]string, []string, error) {
+	var args []string
+	env := os.Environ()
+
+	for _, e := range run.Env {
+		args = append(args, "-e", e)
+	}
+
+	secret_env, err := secrets.ResolveEnv(c, run.Secrets)
+	if err != nil {
+		return args, env, err
+	}
+	for _, e := range secret_env {
+		name := strings.SplitN(e, "=", 2)[0]
+		args = append(args, "-e", name)
+		env = append(env, e)
+	}
+
+	return args, env, nil
+}
+
+// Get docker run arguments for entrypoint, image and the command running
+// the run script. Entrypoint replaces CMD of run image, so the run script
+// is always passed to it. Extra arguments are positional parameters of the
+// run script.
+func getDockerCommandArgs(run config.Run, image string, args []string) []string {
+	var docker_args []string
+
+	if run.Entrypoint != "" {
+		docker_args = append(docker_args, "--entrypoint", run.Entrypoint)
+	}
+	docker_args = append(docker_args, image)
+
+	if run.Entrypoint != "" || len(args) > 0 {
+		docker_args = append(docker_args, "/tmp/wrench_run.sh")
+		docker_args = append(docker_args, args...)
+	}
+
+	return docker_args
+}
+
+// Get docker run arguments for volumes, workdir and user
+func getDockerRunArgs(run config.Run, dir string) []string {
+	var args []string
+
+	for _, volume := range run.Volumes {
+		parts := strings.SplitN(volume, ":", 2)
+
+		// Relative host paths are relative to the project directory,
+		// anything else without a slash is a named docker volume
+		if strings.HasPrefix(parts[0], ".") {
+			parts[0] = filepath.Join(dir, parts[0])
+		}
+
+		args = append(args, "-v", strings.Join(parts, ":"))
+	}
+
+	if run.Workdir != "" {
+		args = append(args, "-w", run.Workdir)
+	}
+
+	if run.User == "host" {
+		// Run as the host user so files written to mounts are owned by it
+		args = append(args, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	} else if run.User != "" {
+		args = append(args, "-u", run.User)
+	}
+
+	return args
 }
@@ -0,0 +1,39 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/config"
+)
+
+var mocked_functions = map[string]interface{}{
+	"runCommand": runCommand,
+}
+
+type RunTestSuite struct {
+	suite.Suite
+}
+
+func TestRunTestSuite(t *testing.T) {
+	suite.Run(t, new(RunTestSuite))
+}
+
+func (suite *RunTestSuite) TestGetDockerCommandArgs() {
+	assert.Equal(suite.T(), []string{"acme/api:v1.0.0-run"},
+		getDockerCommandArgs(config.Run{Cmd: "make test"}, "acme/api:v1.0.0-run", nil))
+
+	assert.Equal(suite.T(), []string{"acme/api:v1.0.0-run", "/tmp/wrench_run.sh", "-v", "./..."},
+		getDockerCommandArgs(config.Run{Cmd: "go test $@"}, "acme/api:v1.0.0-run", []string{"-v", "./..."}))
+}
+
+func (suite *RunTestSuite) TestGetDockerCommandArgsEntrypoint() {
+	run := config.Run{Cmd: "make test", Entrypoint: "/bin/bash"}
+
+	assert.Equal(suite.T(), []string{"--entrypoint", "/bin/bash", "acme/api:v1.0.0-run", "/tmp/wrench_run.sh"},
+		getDockerCommandArgs(run, "acme/api:v1.0.0-run", nil))
+
+	assert.Equal(suite.T(), []string{"--entrypoint", "/bin/bash", "acme/api:v1.0.0-run", "/tmp/wrench_run.sh", "unit"},
+		getDockerCommandArgs(run, "acme/api:v1.0.0-run", []string{"unit"}))
+}
@@ -0,0 +1,117 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Time to wait for a service to become healthy
+var serviceTimeout = 2 * time.Minute
+
+// Start services on a private network and return env variables with
+// the hostname of each service for the run container
+func startServices(ctx context.Context, services map[string]config.Service, network string, c *cleanup) ([]string, error) {
+	var env []string
+
+	if exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("docker network create '%s'", network)); exitcode != 0 {
+		return env, errors.New(fmt.Sprintf("Could not create network %s: %s", network, out))
+	}
+	c.Add(func() {
+		utils.RunCmd(fmt.Sprintf("docker network rm '%s'", network))
+	})
+
+	// Start services in a stable order
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var containers []string
+	for _, name := range names {
+		service := services[name]
+		container := fmt.Sprintf("%s-%s", network, name)
+
+		log.Infof("starting service %s from image %s", name, service.Image)
+
+		args := []string{"docker", "run", "-d",
+			"--name", container,
+			"--network", network,
+			"--network-alias", name}
+		for _, e := range service.Env {
+			args = append(args, "-e", e)
+		}
+		if service.HealthCmd != "" {
+			args = append(args,
+				"--health-cmd", service.HealthCmd,
+				"--health-interval", "1s")
+		}
+		args = append(args, service.Image)
+		args = append(args, service.Cmd...)
+
+		if exitcode, out := utils.RunCmdContext(ctx, utils.ShellQuoteArgs(args)); exitcode != 0 {
+			return env, errors.New(fmt.Sprintf("Could not start service %s: %s", name, out))
+		}
+		c.Add(func() {
+			utils.RunCmd(fmt.Sprintf("docker rm -f -v '%s'", container))
+		})
+
+		containers = append(containers, container)
+		env = append(env, fmt.Sprintf("%s_HOST=%s", serviceEnvName(name), name))
+	}
+
+	for i, container := range containers {
+		if err := waitForService(ctx, names[i], container); err != nil {
+			return env, err
+		}
+	}
+
+	return env, nil
+}
+
+// Wait for service container to report healthy, or running if the image
+// has no health check
+func waitForService(ctx context.Context, name string, container string) error {
+	format := "{{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}}"
+	deadline := time.Now().Add(serviceTimeout)
+
+	for {
+		exitcode, out := utils.RunCmdContext(ctx, fmt.Sprintf("docker inspect -f '%s' '%s'", format, container))
+		if exitcode != 0 {
+			return errors.New(fmt.Sprintf("Could not inspect service %s: %s", name, out))
+		}
+
+		switch strings.TrimSpace(out) {
+		case "healthy", "running":
+			return nil
+		case "unhealthy", "exited", "dead":
+			_, logs := utils.RunCmd(fmt.Sprintf("docker logs --tail 20 '%s'", container))
+			fmt.Fprintln(os.Stderr, logs)
+			return errors.New(fmt.Sprintf("Service %s is %s", name, strings.TrimSpace(out)))
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New(fmt.Sprintf("Timed out waiting for service %s to become healthy", name))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Convert service name to env variable prefix, postgres-main => POSTGRES_MAIN
+func serviceEnvName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
@@ -0,0 +1,115 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/errdefs"
+	"github.com/tomologic/wrench/log"
+	"github.com/tomologic/wrench/utils"
+)
+
+// Options of shell. Image is test, builder or final, Run is a run command
+// whose env, mounts and services are used.
+type ShellOptions struct {
+	Image   string
+	Run     string
+	Command []string
+}
+
+// Run command in project image, or an interactive shell if command is
+// empty. Returns a RunError if the command exits with non-zero exit code.
+func Shell(ctx context.Context, c *config.Resolved, options ShellOptions) error {
+	image := options.Image
+	run_name := options.Run
+	command := options.Command
+
+	run := config.Run{}
+	if run_name != "" {
+		var ok bool
+		if run, ok = c.GetRun(run_name); !ok {
+			return &errdefs.ConfigError{Err: errors.New(fmt.Sprintf("%s not found in wrench.yml", run_name))}
+		}
+	}
+
+	if image != "" {
+		if !utils.StringInSlice(image, config.RunImages) {
+			return errors.New(fmt.Sprintf("Image must be one of %s", strings.Join(config.RunImages, ", ")))
+		}
+		run.Image = image
+	}
+
+	image_name, err := getRunImageName(c, run.Image)
+	if err != nil {
+		return err
+	}
+
+	cleanup := newCleanup()
+	defer cleanup.Run()
+
+	// Name used for container and services network
+	container_name := fmt.Sprintf("wrench_shell_%d", os.Getpid())
+
+	// Keep stdin open so commands can be piped, only allocate a tty when
+	// attached to a terminal
+	docker_args := []string{"run", "--rm", "-i", "--name", container_name}
+	if utils.IsTerminal(os.Stdin) && utils.IsTerminal(os.Stdout) {
+		docker_args = append(docker_args, "-t")
+	}
+
+	env_args, env, err := getDockerEnv(c, run)
+	if err != nil {
+		return err
+	}
+	docker_args = append(docker_args, env_args...)
+
+	if len(run.Services) > 0 {
+		service_env, err := startServices(ctx, run.Services, container_name, cleanup)
+		if err != nil {
+			return err
+		}
+
+		docker_args = append(docker_args, "--network", container_name)
+		for _, e := range service_env {
+			docker_args = append(docker_args, "-e", e)
+		}
+	}
+
+	docker_args = append(docker_args, getDockerRunArgs(run, c.Dir())...)
+
+	if len(command) == 0 {
+		shell := run.Entrypoint
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		log.Infof("opening %s in image %s", filepath.Base(shell), image_name)
+		docker_args = append(docker_args, "--entrypoint", shell, image_name)
+	} else {
+		docker_args = append(docker_args, "--entrypoint", command[0], image_name)
+		docker_args = append(docker_args, command[1:]...)
+	}
+
+	cleanup.Add(func() {
+		utils.RunCmd(fmt.Sprintf("docker rm -f '%s'", container_name))
+	})
+
+	cmd := utils.CommandContext(ctx, "docker", docker_args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// Command in container failed, it has already reported why
+		if exitcode := utils.GetCommandExitCode(err); exitcode != 0 {
+			return &errdefs.RunError{Name: "shell", ExitCode: exitcode}
+		}
+		return err
+	}
+
+	return nil
+}
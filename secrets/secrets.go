@@ -0,0 +1,218 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/utils"
+)
+
+const mask = "***"
+
+var registered_mutex sync.Mutex
+var registered []string
+
+var getEnv = func(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+var getHomeDir = func() (string, error) {
+	return os.UserHomeDir()
+}
+
+// Resolve value of secret from env variable, file or command output. The
+// value is registered so it's masked in output written through Mask.
+func Resolve(name string, secret config.Secret) (string, error) {
+	return resolve(name, secret, "")
+}
+
+// Resolve secret with relative files and commands in dir, current
+// directory if empty
+func resolve(name string, secret config.Secret, dir string) (string, error) {
+	var value string
+
+	switch {
+	case secret.Env != "":
+		v, ok := getEnv(secret.Env)
+		if !ok {
+			return "", errors.New(fmt.Sprintf("Env variable %s for secret %s not set", secret.Env, name))
+		}
+		value = v
+	case secret.File != "":
+		path := secret.File
+		if strings.HasPrefix(path, "~/") {
+			home, err := getHomeDir()
+			if err != nil {
+				return "", err
+			}
+			path = filepath.Join(home, path[2:])
+		} else if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Unable to read file for secret %s: %s", name, err))
+		}
+		value = strings.TrimRight(string(content), "\r\n")
+	case secret.Command != "":
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", secret.Command)
+		cmd.Dir = dir
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Command for secret %s failed: %s %s", name, err, strings.TrimSpace(stderr.String())))
+		}
+		value = strings.TrimRight(string(out), "\r\n")
+	default:
+		return "", errors.New(fmt.Sprintf("No source for secret %s", name))
+	}
+
+	register(value)
+
+	return value, nil
+}
+
+// Resolve secrets of project by name and return them as NAME=value env
+// variables. Relative files and commands are resolved in the project
+// directory.
+func ResolveEnv(c *config.Resolved, names []string) ([]string, error) {
+	var env []string
+
+	for _, name := range names {
+		secret, ok := c.GetSecret(name)
+		if !ok {
+			return env, errors.New(fmt.Sprintf("Secret %s not found in wrench.yml", name))
+		}
+
+		value, err := resolve(name, secret, c.Dir())
+		if err != nil {
+			return env, err
+		}
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	return env, nil
+}
+
+var dockerfileSecretRegexp = regexp.MustCompile(`--mount=(\S*type=secret\S*)`)
+
+// Get ids of secrets mounted by RUN instructions in dockerfile
+func GetDockerfileSecretIds(dockerfile string) []string {
+	var names []string
+
+	for _, match := range dockerfileSecretRegexp.FindAllStringSubmatch(dockerfile, -1) {
+		options := make(map[string]string)
+		for _, option := range strings.Split(match[1], ",") {
+			parts := strings.SplitN(option, "=", 2)
+			if len(parts) == 2 {
+				options[parts[0]] = parts[1]
+			}
+		}
+
+		// Id defaults to target file name
+		name := options["id"]
+		if name == "" && options["target"] != "" {
+			name = filepath.Base(options["target"])
+		}
+
+		if name != "" && !utils.StringInSlice(name, names) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func register(value string) {
+	if value == "" {
+		return
+	}
+
+	registered_mutex.Lock()
+	defer registered_mutex.Unlock()
+
+	if !utils.StringInSlice(value, registered) {
+		registered = append(registered, value)
+	}
+}
+
+func getRegistered() []string {
+	registered_mutex.Lock()
+	defer registered_mutex.Unlock()
+
+	return append([]string{}, registered...)
+}
+
+// Replace all resolved secret values in string
+func Mask(s string) string {
+	for _, value := range getRegistered() {
+		s = strings.Replace(s, value, mask, -1)
+	}
+	return s
+}
+
+// Writer masking resolved secret values. Output that could be the start of
+// a secret is held back until the next write or Flush.
+type MaskWriter struct {
+	w       io.Writer
+	mutex   sync.Mutex
+	pending string
+}
+
+func NewMaskWriter(w io.Writer) *MaskWriter {
+	return &MaskWriter{w: w}
+}
+
+func (m *MaskWriter) Write(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	values := getRegistered()
+
+	data := m.pending + string(p)
+	for _, value := range values {
+		data = strings.Replace(data, value, mask, -1)
+	}
+
+	// Hold back longest suffix that is the start of a secret
+	hold := 0
+	for _, value := range values {
+		for i := len(value) - 1; i > hold; i-- {
+			if strings.HasSuffix(data, value[:i]) {
+				hold = i
+				break
+			}
+		}
+	}
+
+	m.pending = data[len(data)-hold:]
+	if _, err := io.WriteString(m.w, data[:len(data)-hold]); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Write output held back
+func (m *MaskWriter) Flush() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, err := io.WriteString(m.w, m.pending)
+	m.pending = ""
+
+	return err
+}
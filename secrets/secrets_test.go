@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/config"
+)
+
+type SecretsTestSuite struct {
+	suite.Suite
+}
+
+func TestSecretsTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretsTestSuite))
+}
+
+func (suite *SecretsTestSuite) TearDownTest() {
+	registered = nil
+}
+
+func (suite *SecretsTestSuite) TestResolveEnv() {
+	suite.T().Setenv("WRENCH_TEST_SECRET", "s3cr3t")
+
+	value, err := Resolve("TOKEN", config.Secret{Env: "WRENCH_TEST_SECRET"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "s3cr3t", value)
+}
+
+func (suite *SecretsTestSuite) TestResolveEnvNotSet() {
+	_, err := Resolve("TOKEN", config.Secret{Env: "WRENCH_TEST_SECRET_NOT_SET"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Env variable WRENCH_TEST_SECRET_NOT_SET for secret TOKEN not set", err.Error())
+	}
+}
+
+func (suite *SecretsTestSuite) TestResolveFile() {
+	path := filepath.Join(suite.T().TempDir(), "secret")
+	ioutil.WriteFile(path, []byte("s3cr3t\n"), 0600)
+
+	value, err := Resolve("TOKEN", config.Secret{File: path})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "s3cr3t", value)
+}
+
+func (suite *SecretsTestSuite) TestResolveFileHome() {
+	home := suite.T().TempDir()
+	ioutil.WriteFile(filepath.Join(home, "secret"), []byte("s3cr3t"), 0600)
+
+	getHomeDir = func() (string, error) { return home, nil }
+	defer func() { getHomeDir = os.UserHomeDir }()
+
+	value, err := Resolve("TOKEN", config.Secret{File: "~/secret"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "s3cr3t", value)
+}
+
+func (suite *SecretsTestSuite) TestResolveCommand() {
+	value, err := Resolve("TOKEN", config.Secret{Command: "echo s3cr3t"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "s3cr3t", value)
+}
+
+func (suite *SecretsTestSuite) TestResolveCommandFailed() {
+	_, err := Resolve("TOKEN", config.Secret{Command: "echo not found >&2; exit 3"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "Command for secret TOKEN failed: exit status 3 not found", err.Error())
+	}
+}
+
+func (suite *SecretsTestSuite) TestMask() {
+	Resolve("TOKEN", config.Secret{Command: "echo s3cr3t"})
+
+	assert.Equal(suite.T(), "token *** used", Mask("token s3cr3t used"))
+}
+
+func (suite *SecretsTestSuite) TestMaskWriterSplitWrites() {
+	Resolve("TOKEN", config.Secret{Command: "echo s3cr3t"})
+
+	var out bytes.Buffer
+	w := NewMaskWriter(&out)
+
+	w.Write([]byte("token s3c"))
+	assert.Equal(suite.T(), "token ", out.String())
+
+	w.Write([]byte("r3t used s3"))
+	assert.Equal(suite.T(), "token *** used ", out.String())
+
+	w.Flush()
+	assert.Equal(suite.T(), "token *** used s3", out.String())
+}
+
+func (suite *SecretsTestSuite) TestGetDockerfileSecretIds() {
+	dockerfile := "FROM alpine\n" +
+		"RUN --mount=type=secret,id=NPM_TOKEN npm install\n" +
+		"RUN --mount=type=secret,target=/run/secrets/API_KEY,required cat /run/secrets/API_KEY\n" +
+		"RUN --mount=type=cache,target=/root/.cache pip install .\n" +
+		"RUN --mount=type=secret,id=NPM_TOKEN npm test\n"
+
+	assert.Equal(suite.T(), []string{"NPM_TOKEN", "API_KEY"}, GetDockerfileSecretIds(dockerfile))
+}
@@ -0,0 +1,187 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Versioning strategies, git tags are always vX.Y.Z and strategies only
+// differ in how snapshot and release versions are formatted
+const (
+	// v1.2.0-5-gabc123
+	StrategyDescribe = "describe"
+	// v1.2.0-feature-x.5.gabc123 outside main branches
+	StrategyBranchSha = "branch-sha"
+	// v2024.5.0-5-gabc123, released as vYYYY.M.MICRO
+	StrategyCalver = "calver"
+	// 1.2.1.dev5+gabc123
+	StrategyPep440 = "pep440"
+	// 1.2.1-5-gabc123-SNAPSHOT
+	StrategyMaven = "maven"
+)
+
+var Strategies = []string{StrategyDescribe, StrategyBranchSha, StrategyCalver, StrategyPep440, StrategyMaven}
+
+// Revision relative to latest semver tag, like git describe. Sha is empty
+// if revision is tagged.
+type Revision struct {
+	Tag     Semver
+	Commits int
+	Sha     string
+
+	// Only used by branch-sha, empty on main branches
+	Branch string
+}
+
+var describeRegexp = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)(?:-(\d+)-g([0-9a-f]+))?$`)
+var pep440Regexp = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:\.dev(\d+)(?:\+(.*))?)?$`)
+var mavenRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-(.*)-SNAPSHOT)?$`)
+var branchRegexp = regexp.MustCompile(`[^0-9a-z]+`)
+
+// Parse git describe output, vX.Y.Z or vX.Y.Z-N-gSHA
+func ParseDescribe(s string) (Revision, error) {
+	match := describeRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return Revision{}, fmt.Errorf("unable to parse '%s' as git describe output", s)
+	}
+
+	tag, err := Parse(match[1])
+	if err != nil {
+		return Revision{}, err
+	}
+
+	revision := Revision{Tag: tag, Sha: match[3]}
+	if match[2] != "" {
+		revision.Commits, _ = strconv.Atoi(match[2])
+	}
+
+	return revision, nil
+}
+
+// Format revision as version with strategy
+func Format(strategy string, r Revision) (string, error) {
+	tag := r.Tag
+	tag.Snapshot = ""
+
+	if r.Sha == "" {
+		return FormatRelease(strategy, tag)
+	}
+
+	// Snapshots of python and java are versions before next patch release
+	next := tag
+	next.Patch += 1
+
+	switch strategy {
+	case StrategyDescribe, StrategyCalver:
+		return fmt.Sprintf("%s-%d-g%s", tag.String(), r.Commits, r.Sha), nil
+	case StrategyBranchSha:
+		branch := strings.Trim(branchRegexp.ReplaceAllString(strings.ToLower(r.Branch), "-"), "-")
+		if branch == "" {
+			return fmt.Sprintf("%s-%d-g%s", tag.String(), r.Commits, r.Sha), nil
+		}
+		return fmt.Sprintf("%s-%s.%d.g%s", tag.String(), branch, r.Commits, r.Sha), nil
+	case StrategyPep440:
+		return fmt.Sprintf("%d.%d.%d.dev%d+g%s", next.Major, next.Minor, next.Patch, r.Commits, r.Sha), nil
+	case StrategyMaven:
+		return fmt.Sprintf("%d.%d.%d-%d-g%s-SNAPSHOT", next.Major, next.Minor, next.Patch, r.Commits, r.Sha), nil
+	}
+
+	return "", fmt.Errorf("unknown versioning strategy '%s'", strategy)
+}
+
+// Format release version with strategy, python and java versions have no
+// leading v
+func FormatRelease(strategy string, s Semver) (string, error) {
+	switch strategy {
+	case StrategyDescribe, StrategyBranchSha, StrategyCalver:
+		return s.String(), nil
+	case StrategyPep440, StrategyMaven:
+		return strings.TrimLeft(s.String(), "v"), nil
+	}
+	return "", fmt.Errorf("unknown versioning strategy '%s'", strategy)
+}
+
+// Parse version formatted with strategy. Snapshots are returned relative to
+// the tag they are based on so Bump gives the same result for every
+// strategy.
+func ParseStrategy(strategy string, s string) (Semver, error) {
+	switch strategy {
+	case StrategyDescribe, StrategyBranchSha, StrategyCalver:
+		return Parse(s)
+	case StrategyPep440:
+		if match := pep440Regexp.FindStringSubmatch(s); match != nil {
+			snapshot := ""
+			if match[4] != "" {
+				snapshot = "dev" + match[4]
+				if match[5] != "" {
+					snapshot += "+" + match[5]
+				}
+			}
+			return parseSnapshotOfNext(match[1], match[2], match[3], snapshot)
+		}
+		return Semver{}, fmt.Errorf("unable to parse '%s' as pep440 version", s)
+	case StrategyMaven:
+		if match := mavenRegexp.FindStringSubmatch(s); match != nil {
+			snapshot := ""
+			if match[4] != "" {
+				snapshot = match[4] + "-SNAPSHOT"
+			}
+			return parseSnapshotOfNext(match[1], match[2], match[3], snapshot)
+		}
+		return Semver{}, fmt.Errorf("unable to parse '%s' as maven version", s)
+	}
+	return Semver{}, fmt.Errorf("unknown versioning strategy '%s'", strategy)
+}
+
+// Parse docker image tag of version with strategy. Image tags have + of
+// versions replaced with -, like 1.2.1.dev5-gabc123.
+func ParseStrategyTag(strategy string, tag string) (Semver, error) {
+	version, err := ParseStrategy(strategy, tag)
+	if err == nil {
+		return version, nil
+	}
+
+	if version, tag_err := ParseStrategy(strategy, strings.Replace(tag, "-", "+", 1)); tag_err == nil {
+		return version, nil
+	}
+	return version, err
+}
+
+func parseSnapshotOfNext(major string, minor string, patch string, snapshot string) (Semver, error) {
+	sv, err := Parse(fmt.Sprintf("%s.%s.%s", major, minor, patch))
+	if err != nil {
+		return sv, err
+	}
+
+	if snapshot != "" {
+		sv.Snapshot = snapshot
+		if sv.Patch > 0 {
+			sv.Patch -= 1
+		}
+	}
+
+	return sv, nil
+}
+
+// Bump version with strategy. Calver ignores level and releases the next
+// micro version of the current month.
+func (s *Semver) BumpStrategy(strategy string, level string, now time.Time) error {
+	if strategy != StrategyCalver {
+		return s.Bump(level)
+	}
+
+	year, month := now.Year(), int(now.Month())
+	if s.Major == year && s.Minor == month {
+		s.Patch += 1
+	} else {
+		s.Major = year
+		s.Minor = month
+		s.Patch = 0
+	}
+	s.Snapshot = ""
+
+	return nil
+}
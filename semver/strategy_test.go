@@ -0,0 +1,155 @@
+package semver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type StrategyTestSuite struct {
+	suite.Suite
+}
+
+func TestStrategyTestSuite(t *testing.T) {
+	suite.Run(t, new(StrategyTestSuite))
+}
+
+func (suite *StrategyTestSuite) TestParseDescribe() {
+	var examples = []struct {
+		Input    string
+		Expected Revision
+	}{
+		{"v1.2.0", Revision{Tag: Semver{1, 2, 0, ""}}},
+		{"v1.2.0-5-gabc123", Revision{Tag: Semver{1, 2, 0, ""}, Commits: 5, Sha: "abc123"}},
+		{"v0.0.0-12-g1234567\n", Revision{Tag: Semver{0, 0, 0, ""}, Commits: 12, Sha: "1234567"}},
+	}
+
+	for _, ex := range examples {
+		revision, err := ParseDescribe(ex.Input)
+
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Expected, revision)
+		}
+	}
+}
+
+func (suite *StrategyTestSuite) TestParseDescribeInvalid() {
+	_, err := ParseDescribe("foobar")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "unable to parse 'foobar' as git describe output", err.Error())
+	}
+}
+
+func (suite *StrategyTestSuite) TestFormat() {
+	snapshot := Revision{Tag: Semver{1, 2, 0, ""}, Commits: 5, Sha: "abc123", Branch: "Feature/X"}
+	release := Revision{Tag: Semver{1, 2, 0, ""}, Branch: "feature/x"}
+
+	var examples = []struct {
+		Strategy string
+		Input    Revision
+		Expected string
+	}{
+		{StrategyDescribe, snapshot, "v1.2.0-5-gabc123"},
+		{StrategyDescribe, release, "v1.2.0"},
+		{StrategyBranchSha, snapshot, "v1.2.0-feature-x.5.gabc123"},
+		{StrategyBranchSha, Revision{Tag: Semver{1, 2, 0, ""}, Commits: 5, Sha: "abc123"}, "v1.2.0-5-gabc123"},
+		{StrategyBranchSha, release, "v1.2.0"},
+		{StrategyCalver, snapshot, "v1.2.0-5-gabc123"},
+		{StrategyPep440, snapshot, "1.2.1.dev5+gabc123"},
+		{StrategyPep440, release, "1.2.0"},
+		{StrategyMaven, snapshot, "1.2.1-5-gabc123-SNAPSHOT"},
+		{StrategyMaven, release, "1.2.0"},
+		{StrategyDescribe, Revision{Sha: "abc123"}, "v0.0.0-0-gabc123"},
+	}
+
+	for _, ex := range examples {
+		version, err := Format(ex.Strategy, ex.Input)
+
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Expected, version, ex.Strategy)
+		}
+	}
+}
+
+func (suite *StrategyTestSuite) TestFormatUnknown() {
+	_, err := Format("foobar", Revision{Commits: 1, Sha: "abc123"})
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "unknown versioning strategy 'foobar'", err.Error())
+	}
+}
+
+func (suite *StrategyTestSuite) TestParseStrategy() {
+	var examples = []struct {
+		Strategy string
+		Input    string
+		Expected Semver
+	}{
+		{StrategyDescribe, "v1.2.0-5-gabc123", Semver{1, 2, 0, "5-gabc123"}},
+		{StrategyBranchSha, "v1.2.0-feature-x.5.gabc123", Semver{1, 2, 0, "feature-x.5.gabc123"}},
+		{StrategyPep440, "1.2.1.dev5+gabc123", Semver{1, 2, 0, "dev5+gabc123"}},
+		{StrategyPep440, "1.2.0", Semver{1, 2, 0, ""}},
+		{StrategyMaven, "1.2.1-5-gabc123-SNAPSHOT", Semver{1, 2, 0, "5-gabc123-SNAPSHOT"}},
+		{StrategyMaven, "1.2.0", Semver{1, 2, 0, ""}},
+	}
+
+	for _, ex := range examples {
+		version, err := ParseStrategy(ex.Strategy, ex.Input)
+
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Expected, version, ex.Strategy)
+		}
+	}
+}
+
+func (suite *StrategyTestSuite) TestParseStrategyInvalid() {
+	_, err := ParseStrategy(StrategyPep440, "v1.2.0-5-gabc123")
+
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), "unable to parse 'v1.2.0-5-gabc123' as pep440 version", err.Error())
+	}
+}
+
+func (suite *StrategyTestSuite) TestParseStrategyTag() {
+	version, err := ParseStrategyTag(StrategyPep440, "1.2.1.dev5-gabc123")
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), Semver{1, 2, 0, "dev5+gabc123"}, version)
+	}
+
+	_, err = ParseStrategyTag(StrategyDescribe, "main-latest")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *StrategyTestSuite) TestBumpStrategyCalver() {
+	now := time.Date(2024, time.May, 17, 0, 0, 0, 0, time.UTC)
+
+	var examples = []struct {
+		Input    Semver
+		Expected Semver
+	}{
+		{Semver{0, 0, 0, "5-gabc123"}, Semver{2024, 5, 0, ""}},
+		{Semver{2024, 4, 2, "5-gabc123"}, Semver{2024, 5, 0, ""}},
+		{Semver{2024, 5, 0, "5-gabc123"}, Semver{2024, 5, 1, ""}},
+	}
+
+	for _, ex := range examples {
+		err := ex.Input.BumpStrategy(StrategyCalver, "minor", now)
+
+		if assert.Nil(suite.T(), err) {
+			assert.Equal(suite.T(), ex.Expected, ex.Input)
+		}
+	}
+}
+
+func (suite *StrategyTestSuite) TestBumpStrategySemver() {
+	version := Semver{1, 2, 0, "dev5+gabc123"}
+
+	err := version.BumpStrategy(StrategyPep440, "patch", time.Now())
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), Semver{1, 2, 1, ""}, version)
+	}
+}
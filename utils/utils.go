@@ -3,16 +3,30 @@ package utils
 import (
 	"archive/tar"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"golang.org/x/term"
 )
 
 var docker_client *docker.Client
+var docker_client_once sync.Once
+
+func getDockerClient() *docker.Client {
+	docker_client_once.Do(func() {
+		docker_client, _ = docker.NewClientFromEnv()
+	})
+	return docker_client
+}
 
 func FileExists(filename string) bool {
 	_, err := os.Stat(filename)
@@ -28,61 +42,84 @@ func GetCommandExitCode(err error) int {
 	return 0
 }
 
-func GetFileContent(path string) string {
-	var content string
-	content_bytes, err := ioutil.ReadFile(path)
-	content = string(content_bytes)
-
+func GetFileContent(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "", err
 	}
-	return content
+	return string(content), nil
 }
 
-func WriteFileContent(filename string, content string) {
-	content_bytes := []byte(content)
-	err := ioutil.WriteFile(filename, content_bytes, 0644)
+func WriteFileContent(filename string, content string) error {
+	return ioutil.WriteFile(filename, []byte(content), 0644)
+}
 
+func DockerImageExists(name string) (bool, error) {
+	if _, err := getDockerClient().InspectImage(name); err == docker.ErrNoSuchImage {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Local docker image
+type DockerImage struct {
+	ID          string
+	Size        int64
+	RepoDigests []string
+}
+
+func DockerInspectImage(name string) (DockerImage, error) {
+	image, err := getDockerClient().InspectImage(name)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return DockerImage{}, err
 	}
+	return DockerImage{ID: image.ID, Size: image.Size, RepoDigests: image.RepoDigests}, nil
 }
 
-func DockerImageExists(name string) bool {
-	if docker_client == nil {
-		docker_client, _ = docker.NewClientFromEnv()
+// Tag of local image with the time image was created
+type DockerImageTag struct {
+	Name    string
+	Created time.Time
+}
+
+// Get tags of local images in repository
+func DockerListImageTags(repository string) ([]DockerImageTag, error) {
+	images, err := getDockerClient().ListImages(docker.ListImagesOptions{
+		Filters: map[string][]string{"reference": {repository}},
+	})
+	if err != nil {
+		return nil, err
 	}
-	if _, err := docker_client.InspectImage(name); err == docker.ErrNoSuchImage {
-		return false
-	} else if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+
+	var tags []DockerImageTag
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if strings.HasPrefix(tag, repository+":") {
+				tags = append(tags, DockerImageTag{Name: tag, Created: time.Unix(image.Created, 0)})
+			}
+		}
 	}
-	return true
+	return tags, nil
 }
 
-func DockerRemoveImage(name string) bool {
-	if docker_client == nil {
-		docker_client, _ = docker.NewClientFromEnv()
-	}
-	err := docker_client.RemoveImage(name)
+func DockerRemoveImage(name string) (bool, error) {
+	err := getDockerClient().RemoveImage(name)
 	if err == docker.ErrNoSuchImage {
-		return false
+		return false, nil
 	} else if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return false, err
 	}
-	return true
+	return true, nil
 }
 
-func DockerImageAddEnv(image, env, value string) error {
+func DockerImageAddEnv(ctx context.Context, image, env, value string) error {
 	// Dockerfile for adding ENV
 	dockerfile := fmt.Sprintf("FROM %s\nENV %s %s\n", image, env, value)
 
 	// Run docker build
-	cmd := exec.Command("docker", "build", "-t", image, "-")
+	cmd := CommandContext(ctx, "docker", "build", "-t", image, "-")
 
 	// Pass dockerfile through stdin
 	cmd.Stdin = bytes.NewReader([]byte(dockerfile))
@@ -96,15 +133,53 @@ func DockerImageAddEnv(image, env, value string) error {
 }
 
 func RunCmd(command string) (int, string) {
+	return RunCmdContext(context.Background(), command)
+}
+
+// Run command in sh, the command is stopped if context is done
+func RunCmdContext(ctx context.Context, command string) (int, string) {
 	exitcode := 0
-	cmd := exec.Command("sh", "-c", command)
+	cmd := ShellCommandContext(ctx, command)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		exitcode = GetCommandExitCode(err)
+
+		// Command could not be started, like when context is already done
+		if exitcode == 0 {
+			return 1, err.Error()
+		}
 	}
 	return exitcode, string(out)
 }
 
+// Time commands get to exit after SIGTERM when their context is done,
+// before they are killed
+const CancelWaitDelay = 10 * time.Second
+
+// Command that gets SIGTERM when ctx is done, so docker can stop
+// containers, and is killed if it hasn't exited after CancelWaitDelay
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = CancelWaitDelay
+	return cmd
+}
+
+// Command run in sh in its own process group. Every process of the
+// command, like both sides of a pipe, gets SIGTERM when ctx is done, so
+// none outlive wrench. Not for commands reading from the terminal.
+func ShellCommandContext(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = CancelWaitDelay
+	return cmd
+}
+
 type Tarfile struct {
 	Name, Content string
 }
@@ -147,3 +222,83 @@ func RemoveEmptyStrings(s []string) []string {
 	}
 	return r
 }
+
+func StringInSlice(s string, list []string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Split command into words like sh, with single and double quotes and
+// backslash escapes, but without expansions
+func SplitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	in_word := false
+	quote := rune(0)
+	escaped := false
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			// In double quotes backslash only escapes some characters
+			if quote == '"' && !strings.ContainsRune("\\\"$`", c) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			in_word = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			in_word = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if in_word {
+				words = append(words, word.String())
+				word.Reset()
+				in_word = false
+			}
+		default:
+			word.WriteRune(c)
+			in_word = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New(fmt.Sprintf("Missing closing %c in '%s'", quote, s))
+	}
+	if escaped {
+		return nil, errors.New(fmt.Sprintf("Trailing backslash in '%s'", s))
+	}
+	if in_word {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Quote string for safe use as a single word in sh
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\\''", -1) + "'"
+}
+
+func ShellQuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -33,6 +35,28 @@ func (suite *UtilsTestSuite) TestRunExitCode1() {
 	}
 }
 
+func (suite *UtilsTestSuite) TestRunCmdContextDone() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	exitcode, out := RunCmdContext(ctx, "echo hello")
+
+	assert.Equal(suite.T(), 1, exitcode)
+	assert.Equal(suite.T(), "context canceled", out)
+}
+
+func (suite *UtilsTestSuite) TestShellCommandContextStopsPipe() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Both sides of the pipe must be stopped for the command to return
+	start := time.Now()
+	_, err := ShellCommandContext(ctx, "sleep 30 | sleep 30").Output()
+
+	assert.NotNil(suite.T(), err)
+	assert.Less(suite.T(), time.Since(start), 5*time.Second)
+}
+
 func (suite *UtilsTestSuite) TestRunExitCodeCustom() {
 	exitcode, out := RunCmd("bash -c 'echo -n test foo bar' && exit 127")
 
@@ -76,3 +100,35 @@ func (suite *UtilsTestSuite) TestCreateTar() {
 		}
 	}
 }
+
+func (suite *UtilsTestSuite) TestShellQuoteArgs() {
+	args := []string{"echo", "hello world", "it's", ""}
+
+	assert.Equal(suite.T(), `'echo' 'hello world' 'it'\''s' ''`, ShellQuoteArgs(args))
+
+	exitcode, out := RunCmd(ShellQuoteArgs(args))
+	if assert.Equal(suite.T(), 0, exitcode) {
+		assert.Equal(suite.T(), "hello world it's \n", out)
+	}
+}
+
+func (suite *UtilsTestSuite) TestSplitShellWords() {
+	for s, expected := range map[string][]string{
+		`postgres -c fsync=off`:                {"postgres", "-c", "fsync=off"},
+		`postgres -c "shared_buffers=256MB"`:   {"postgres", "-c", "shared_buffers=256MB"},
+		`sh -c 'echo "hello world"'`:           {"sh", "-c", `echo "hello world"`},
+		`echo "a \"b\" \c" it\'s ''`:           {"echo", `a "b" \c`, "it's", ""},
+		"  redis-server\t--appendonly  yes \n": {"redis-server", "--appendonly", "yes"},
+		``:                                     nil,
+	} {
+		words, err := SplitShellWords(s)
+		if assert.Nil(suite.T(), err, s) {
+			assert.Equal(suite.T(), expected, words, s)
+		}
+	}
+
+	_, err := SplitShellWords(`postgres -c "fsync=off`)
+	if assert.NotNil(suite.T(), err) {
+		assert.Equal(suite.T(), `Missing closing " in 'postgres -c "fsync=off'`, err.Error())
+	}
+}
@@ -0,0 +1,256 @@
+// Package wrench builds, runs, bumps and pushes docker images of projects
+// configured with wrench.yml. It is the library behind the wrench command,
+// errors are returned instead of exiting so it can be embedded in other
+// tools.
+//
+//	project, err := wrench.Load(".", wrench.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	if err := project.Build(ctx, wrench.BuildOptions{}); err != nil {
+//		return err
+//	}
+//	return project.Run(ctx, "test", wrench.RunOptions{})
+//
+// Projects don't share state, several projects can be loaded and used at
+// the same time. Commands run in the project directory, the working
+// directory of the process is not changed.
+package wrench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/tomologic/wrench/bump"
+	"github.com/tomologic/wrench/clean"
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/errdefs"
+	"github.com/tomologic/wrench/push"
+	"github.com/tomologic/wrench/report"
+	"github.com/tomologic/wrench/run"
+)
+
+// Errors returned by project methods, test with errors.As and errors.Is
+type (
+	ConfigError        = errdefs.ConfigError
+	ImageNotFoundError = errdefs.ImageNotFoundError
+	CommandError       = errdefs.CommandError
+	RunError           = errdefs.RunError
+)
+
+var (
+	ErrAlreadyReleased = errdefs.ErrAlreadyReleased
+	ErrNoDockerfile    = errdefs.ErrNoDockerfile
+)
+
+// Options for loading a project
+type Options struct {
+	// Config file instead of discovered wrench.yml
+	ConfigFile string
+
+	// Dependencies used to detect project values, the git command, the
+	// hostname command and the process environment if not set
+	Git  config.Git
+	Host config.Host
+	Env  config.Env
+}
+
+type BuildOptions struct {
+	// Build image even if it already exists
+	Rebuild bool
+
+	// Only build if files changed since git ref, in a monorepo only
+	// changed projects and projects depending on them
+	ChangedSince string
+
+	// Cache sources and destinations in addition to Cache in wrench.yml,
+	// image references or BuildKit cache specs. Not supported in a
+	// monorepo.
+	CacheFrom []string
+	CacheTo   []string
+
+	// SSH agent sockets or keys, like default, and BuildKit secrets, like
+	// id=npmrc,src=.npmrc, in addition to Build Ssh and Secrets in
+	// wrench.yml
+	Ssh     []string
+	Secrets []string
+
+	// Images built, or found already built, are added to report if set
+	Report *report.Report
+}
+
+type RunOptions = run.Options
+
+type CleanOptions = clean.Options
+
+type ShellOptions = run.ShellOptions
+
+type PushOptions struct {
+	// Tags pushed in addition to those from TagTemplates
+	AdditionalTags []string
+
+	// Tags pushed and their digests are added to report if set
+	Report *report.Report
+}
+
+// Project loaded from wrench.yml, with values not set in config detected
+type Project struct {
+	options config.Options
+
+	// Config is replaced when it's reloaded after bump
+	mutex  sync.Mutex
+	config *config.Resolved
+}
+
+// Load project found from dir, see wrench.yml discovery in README. Errors
+// are returned as ConfigError.
+func Load(dir string, options Options) (*Project, error) {
+	config_options := config.Options{
+		ConfigFile: options.ConfigFile,
+		Git:        options.Git,
+		Host:       options.Host,
+		Env:        options.Env,
+	}
+
+	c, err := config.Load(dir, config_options)
+	if err != nil {
+		return nil, toConfigError(err)
+	}
+
+	// Config is reloaded from the project directory
+	if config_options.ConfigFile != "" {
+		config_options.ConfigFile = c.GetConfigFile()
+	}
+
+	return &Project{options: config_options, config: c}, nil
+}
+
+func toConfigError(err error) error {
+	var config_error *ConfigError
+	if errors.As(err, &config_error) {
+		return err
+	}
+	return &ConfigError{Err: err}
+}
+
+// Get resolved config of project
+func (p *Project) Config() *config.Resolved {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.config
+}
+
+// Load config of project again, to detect version from new tags
+func (p *Project) reload() error {
+	c, err := config.Load(p.Dir(), p.options)
+	if err != nil {
+		return toConfigError(err)
+	}
+
+	p.mutex.Lock()
+	p.config = c
+	p.mutex.Unlock()
+
+	return nil
+}
+
+// Project directory
+func (p *Project) Dir() string {
+	return p.Config().Dir()
+}
+
+// Project image with version tag
+func (p *Project) Image() string {
+	return p.Config().GetProjectImage()
+}
+
+// Project version
+func (p *Project) Version() string {
+	return p.Config().GetProjectVersion()
+}
+
+// Get config as yaml, or value rendered from format template
+func (p *Project) Format(format string) (string, error) {
+	return p.Config().FormatConfig(format)
+}
+
+// Get projects of monorepo in build order, only projects changed since git
+// ref and projects depending on them if changed_since is set
+func (p *Project) Projects(changed_since string) ([]config.SubProject, error) {
+	return p.getProjects(p.Config(), changed_since)
+}
+
+// Build project images, in a monorepo images of every project in
+// dependency order
+func (p *Project) Build(ctx context.Context, options BuildOptions) error {
+	c := p.Config()
+	if c.IsMonorepo() {
+		return p.buildProjects(ctx, c, options)
+	}
+	return build(ctx, c, options)
+}
+
+// Run command from wrench.yml in project image, after the commands it
+// depends on. Returns RunError if a command failed.
+func (p *Project) Run(ctx context.Context, name string, options RunOptions) error {
+	return run.Run(ctx, p.Config(), name, options)
+}
+
+// Run command, or an interactive shell, in project image
+func (p *Project) Shell(ctx context.Context, options ShellOptions) error {
+	return run.Shell(ctx, p.Config(), options)
+}
+
+// Push release image to registry
+func (p *Project) Push(ctx context.Context, registry string, options PushOptions) error {
+	return push.Push(ctx, p.Config(), registry, options.AdditionalTags, options.Report)
+}
+
+// Bump version by level major, minor or patch and return the release
+// version. ErrAlreadyReleased is returned with the version if the
+// revision is already released. Project is reloaded after the bump, so
+// Version returns the release version.
+func (p *Project) Bump(ctx context.Context, level string) (string, error) {
+	release, err := bump.Bump(ctx, p.Config(), level)
+	if err != nil {
+		return release, err
+	}
+
+	return release, p.reload()
+}
+
+// Remove what interrupted runs and builds left behind and snapshot images
+// selected by options, in a monorepo of every project. Returns what was
+// removed, or what would be removed on dry run. Temp dirs and files of
+// monorepo projects are relative to the monorepo directory.
+func (p *Project) Clean(ctx context.Context, options CleanOptions) ([]clean.Item, error) {
+	c := p.Config()
+
+	items, err := clean.Clean(ctx, c, options)
+	if err != nil || !c.IsMonorepo() {
+		return items, err
+	}
+
+	for _, project := range c.GetSubProjects() {
+		sub, err := p.loadSubProject(c, project.Path)
+		if err != nil {
+			return items, err
+		}
+
+		sub_items, err := clean.Clean(ctx, sub, options)
+		for _, item := range sub_items {
+			if item.Kind == "dir" || item.Kind == "file" {
+				item.Name = filepath.Join(project.Path, item.Name)
+			}
+			items = append(items, item)
+		}
+		if err != nil {
+			return items, fmt.Errorf("Clean of project %s failed: %w", project.Path, err)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,285 @@
+package wrench
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomologic/wrench/config"
+	"github.com/tomologic/wrench/image"
+	"github.com/tomologic/wrench/utils"
+)
+
+type WrenchTestSuite struct {
+	suite.Suite
+	dir string
+	cwd string
+}
+
+func TestWrenchTestSuite(t *testing.T) {
+	suite.Run(t, new(WrenchTestSuite))
+}
+
+func (suite *WrenchTestSuite) SetupTest() {
+	// Resolve symlinks since os.Getwd returns resolved path
+	suite.dir, _ = filepath.EvalSymlinks(suite.T().TempDir())
+	suite.cwd, _ = os.Getwd()
+}
+
+func (suite *WrenchTestSuite) writeProject(name string, content string) string {
+	dir := filepath.Join(suite.dir, name)
+	os.MkdirAll(dir, 0755)
+	ioutil.WriteFile(filepath.Join(dir, "wrench.yml"), []byte(content), 0644)
+	return dir
+}
+
+func (suite *WrenchTestSuite) TestLoad() {
+	dir := suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\n")
+
+	project, err := Load(dir, Options{})
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), dir, project.Dir())
+		assert.Equal(suite.T(), "acme/api:v1.2.3", project.Image())
+		assert.Equal(suite.T(), "v1.2.3", project.Version())
+	}
+
+	// Working directory is not changed
+	cwd, _ := os.Getwd()
+	assert.Equal(suite.T(), suite.cwd, cwd)
+}
+
+type env []string
+
+func (e env) Environ() []string {
+	return e
+}
+
+func (suite *WrenchTestSuite) TestLoadEnv() {
+	dir := suite.writeProject("api", "Project:\n  Organization: acme\n  Name: {{ env \"NAME\" }}\n  Version: v1.2.3\n")
+
+	project, err := Load(dir, Options{Env: env{"NAME=web"}})
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "acme/web:v1.2.3", project.Image())
+	}
+}
+
+func (suite *WrenchTestSuite) TestLoadTwoProjects() {
+	api, _ := Load(suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\n"), Options{})
+	web, _ := Load(suite.writeProject("web", "Project:\n  Organization: acme\n  Name: web\n  Version: v2.0.0\n"), Options{})
+
+	assert.Equal(suite.T(), "acme/api:v1.2.3", api.Image())
+	assert.Equal(suite.T(), "acme/web:v2.0.0", web.Image())
+	assert.Equal(suite.T(), "v1.2.3", api.Version())
+}
+
+func (suite *WrenchTestSuite) TestLoadConfigError() {
+	var examples = []struct {
+		Content string
+		Options Options
+	}{
+		{"Project:\n  Name: [api\n", Options{}},
+		{"Run:\n  test:\n    DependsOn: [lint]\n", Options{}},
+		{"Project:\n  Name: api\n", Options{ConfigFile: "missing.yml"}},
+	}
+
+	for _, ex := range examples {
+		_, err := Load(suite.writeProject("invalid", ex.Content), ex.Options)
+
+		var config_error *ConfigError
+		assert.True(suite.T(), errors.As(err, &config_error), ex.Content)
+	}
+}
+
+func (suite *WrenchTestSuite) TestRunUnknownCommand() {
+	project, err := Load(suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\n"), Options{})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	err = project.Run(context.Background(), "missing", RunOptions{})
+
+	var config_error *ConfigError
+	if assert.True(suite.T(), errors.As(err, &config_error)) {
+		assert.Equal(suite.T(), "missing not found in wrench.yml", err.Error())
+	}
+}
+
+func (suite *WrenchTestSuite) TestBuildNoDockerfile() {
+	project, err := Load(suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\n"), Options{})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	err = project.Build(context.Background(), BuildOptions{Rebuild: true})
+
+	assert.True(suite.T(), errors.Is(err, ErrNoDockerfile))
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "api"), project.Dir())
+}
+
+func (suite *WrenchTestSuite) TestCacheCounter() {
+	counter := &cacheCounter{}
+
+	counter.Write([]byte("Step 2/4 : RUN make\n ---> Using ca"))
+	counter.Write([]byte("che\n ---> 1234\n#6 [2/3] RUN make\n#6 CACHED\n#7 CACHED"))
+
+	assert.Equal(suite.T(), 3, counter.Hits())
+}
+
+func (suite *WrenchTestSuite) TestSelectPreviousImage() {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tags := []utils.DockerImageTag{
+		{Name: "acme/api:v1.0.0", Created: now.Add(-100 * time.Hour)},
+		{Name: "acme/api:v1.0.0-1-gaaaaaaa", Created: now.Add(-50 * time.Hour)},
+		{Name: "acme/api:v1.0.0-1-gaaaaaaa-test", Created: now.Add(-10 * time.Hour)},
+		{Name: "acme/api:v1.0.0-2-gbbbbbbb", Created: now},
+		{Name: "acme/api:v1.0.0-1-gaaaaaaa-.wrench_run_123", Created: now.Add(-5 * time.Hour)},
+		{Name: "acme/api:main-latest", Created: now.Add(-1 * time.Hour)},
+	}
+
+	assert.Equal(suite.T(), "acme/api:v1.0.0-1-gaaaaaaa", selectPreviousImage(tags, "describe", "v1.0.0-2-gbbbbbbb"))
+	assert.Equal(suite.T(), "acme/api:v1.0.0-2-gbbbbbbb", selectPreviousImage(tags, "describe", "v1.0.0-3-gccccccc"))
+	assert.Equal(suite.T(), "", selectPreviousImage(tags[:1], "describe", "v1.0.0"))
+}
+
+func (suite *WrenchTestSuite) TestBuildCacheArgs() {
+	registry, _ := config.ParseCacheSpec("acme/api:cache", false)
+	local_from, _ := config.ParseCacheSpec("type=local,src=/cache", false)
+	local_to, _ := config.ParseCacheSpec("type=local,dest=/cache", true)
+	previous, _ := image.Parse("acme/api:v1.0.0")
+
+	assert.Nil(suite.T(), (&buildCache{}).args("", false))
+
+	cache := &buildCache{from: []config.CacheSpec{registry}, previous: []image.Reference{previous}, inline: true}
+	assert.False(suite.T(), cache.needsBuildx())
+	assert.Equal(suite.T(), []string{
+		"--cache-from", "acme/api:cache-test",
+		"--cache-from", "acme/api:v1.0.0-test",
+		"--build-arg", "BUILDKIT_INLINE_CACHE=1",
+	}, cache.args("-test", false))
+
+	cache = &buildCache{from: []config.CacheSpec{local_from}, to: []config.CacheSpec{local_to}, previous: []image.Reference{previous}, inline: true}
+	assert.True(suite.T(), cache.needsBuildx())
+	assert.Equal(suite.T(), []string{
+		"--cache-from", "type=local,src=/cache",
+		"--cache-from", "type=registry,ref=acme/api:v1.0.0",
+		"--cache-to", "type=local,dest=/cache",
+	}, cache.args("", true))
+}
+
+func (suite *WrenchTestSuite) TestBuildBackendCommand() {
+	command, env := (&buildBackend{cache: &buildCache{}}).command("")
+	assert.Equal(suite.T(), "'docker' 'build'", command)
+	assert.Nil(suite.T(), env)
+
+	command, env = (&buildBackend{ssh: []string{"default"}, secrets: []string{"id=npmrc,src=.npmrc"}, cache: &buildCache{}}).command("")
+	assert.Equal(suite.T(), "'docker' 'build' '--ssh' 'default' '--secret' 'id=npmrc,src=.npmrc'", command)
+	assert.Equal(suite.T(), []string{"DOCKER_BUILDKIT=1"}, env)
+
+	command, env = (&buildBackend{buildx: true, builder: "remote", ssh: []string{"default"}, cache: &buildCache{inline: true}}).command("-test")
+	assert.Equal(suite.T(), "'docker' 'buildx' 'build' '--load' '--builder' 'remote' '--ssh' 'default' '--build-arg' 'BUILDKIT_INLINE_CACHE=1'", command)
+	assert.Nil(suite.T(), env)
+}
+
+func (suite *WrenchTestSuite) TestRemoteBuilderName() {
+	name := remoteBuilderName("tcp://buildkitd:1234")
+
+	assert.Equal(suite.T(), 15, len(name))
+	assert.Regexp(suite.T(), "^wrench-[0-9a-f]{8}$", name)
+	assert.NotEqual(suite.T(), name, remoteBuilderName("tcp://buildkitd:1235"))
+}
+
+func (suite *WrenchTestSuite) TestRenderTestDockerfileLegacy() {
+	content, args, err := renderTestDockerfile("FROM\nRUN pip install -r requirements-test.txt\n", "acme/api:v1.0.0", "")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "ARG WRENCH_IMAGE\nFROM ${WRENCH_IMAGE}\nRUN pip install -r requirements-test.txt\n", content)
+		assert.Equal(suite.T(), []string{"--build-arg", "WRENCH_IMAGE=acme/api:v1.0.0"}, args)
+	}
+
+	content, args, err = renderTestDockerfile("# Test image\nFROM builder AS test\nWORKDIR /src\nFROM alpine\n", "acme/api:v1.0.0", "acme/api:v1.0.0-builder")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "ARG WRENCH_BUILDER\n# Test image\nFROM ${WRENCH_BUILDER} AS test\nWORKDIR /src\nFROM alpine\n", content)
+		assert.Equal(suite.T(), []string{"--build-arg", "WRENCH_BUILDER=acme/api:v1.0.0-builder"}, args)
+	}
+}
+
+func (suite *WrenchTestSuite) TestRenderTestDockerfilePlaceholders() {
+	dockerfile := "# syntax=docker/dockerfile:1\nARG PYTHON=3.12\nFROM python:${PYTHON} AS tools\nRUN pip install tox\nFROM {{ builder }} AS build\nFROM --platform=linux/amd64 {{image}}\nCOPY --from=tools /usr/local/bin/tox /usr/local/bin/\n"
+
+	content, args, err := renderTestDockerfile(dockerfile, "acme/api:v1.0.0", "acme/api:v1.0.0-builder")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "# syntax=docker/dockerfile:1\nARG WRENCH_IMAGE\nARG WRENCH_BUILDER\nARG PYTHON=3.12\nFROM python:${PYTHON} AS tools\nRUN pip install tox\nFROM ${WRENCH_BUILDER} AS build\nFROM --platform=linux/amd64 ${WRENCH_IMAGE}\nCOPY --from=tools /usr/local/bin/tox /usr/local/bin/\n", content)
+		assert.Equal(suite.T(), []string{"--build-arg", "WRENCH_IMAGE=acme/api:v1.0.0", "--build-arg", "WRENCH_BUILDER=acme/api:v1.0.0-builder"}, args)
+	}
+}
+
+func (suite *WrenchTestSuite) TestRenderTestDockerfileTemplates() {
+	dockerfile := "FROM {{image}}\nRUN echo '{{ name }}' > tmpl.mustache\nCOPY <<EOF /app/values.tpl\n{{ .Values.image }}\n{{image}}\nEOF\n"
+
+	content, _, err := renderTestDockerfile(dockerfile, "acme/api:v1.0.0", "")
+
+	if assert.Nil(suite.T(), err) {
+		assert.Equal(suite.T(), "ARG WRENCH_IMAGE\nFROM ${WRENCH_IMAGE}\nRUN echo '{{ name }}' > tmpl.mustache\nCOPY <<EOF /app/values.tpl\n{{ .Values.image }}\n{{image}}\nEOF\n", content)
+	}
+}
+
+func (suite *WrenchTestSuite) TestRenderTestDockerfileErrors() {
+	for dockerfile, message := range map[string]string{
+		"":                       "Dockerfile.test has no FROM instruction",
+		"# FROM\nRUN true\n":     "Dockerfile.test line 2: RUN before first FROM, only ARG is allowed",
+		"FROM builder\n":         "Dockerfile.test line 1: based on builder image, but project has no Dockerfile.builder",
+		"FROM {{final}}\n":       "Dockerfile.test line 1: unknown placeholder {{final}}, use {{image}} or {{builder}}",
+		"FROM {{image}}\nFROM\n": "Dockerfile.test line 2: FROM without base image, use FROM {{image}} or FROM {{builder}}",
+		"FROM {{image}}\nCOPY --from={{image}} /a /b\n": "Dockerfile.test line 2: placeholders are only supported as base image in FROM",
+		"FROM {{image}}\nRUN echo {{ builder }}\n":      "Dockerfile.test line 2: placeholders are only supported as base image in FROM",
+		"FROM alpine foo\n":                             "Dockerfile.test line 1: invalid FROM 'alpine foo', expected [--flag...] image [AS name]",
+		"FROM alpine\nRUN <<EOF\n":                      "Invalid Dockerfile.test: line 2: heredoc EOF is not terminated",
+	} {
+		_, _, err := renderTestDockerfile(dockerfile, "acme/api:v1.0.0", "")
+		if assert.NotNil(suite.T(), err, dockerfile) {
+			assert.Equal(suite.T(), message, err.Error())
+		}
+	}
+}
+
+func (suite *WrenchTestSuite) TestGetLatestReleaseNoGit() {
+	project, err := Load(suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\n"), Options{})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	release, err := getLatestRelease(project.Config())
+
+	assert.Equal(suite.T(), "", release)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *WrenchTestSuite) TestGetBuildCacheNotUsed() {
+	project, err := Load(suite.writeProject("api", "Project:\n  Organization: acme\n  Name: api\n  Version: v1.2.3\nCache:\n  Previous: false\n"), Options{})
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+
+	cache, err := getBuildCache(project.Config(), BuildOptions{})
+	if assert.Nil(suite.T(), err) {
+		assert.False(suite.T(), cache.inline)
+		command, env := (&buildBackend{cache: cache}).command("")
+		assert.Equal(suite.T(), "'docker' 'build'", command)
+		assert.Nil(suite.T(), env)
+	}
+
+	cache, err = getBuildCache(project.Config(), BuildOptions{CacheFrom: []string{"acme/api:cache"}})
+	if assert.Nil(suite.T(), err) {
+		assert.True(suite.T(), cache.inline)
+	}
+}